@@ -0,0 +1,13 @@
+package app
+
+// AppType 小程序类型
+type AppType int
+
+const (
+	// WxApp 微信小程序
+	WxApp AppType = iota
+	// AlipayApp 支付宝
+	AlipayApp
+	// DouYin 抖音小程序
+	DouYin
+)
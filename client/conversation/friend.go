@@ -0,0 +1,173 @@
+package conversation
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrSelfRequest 不能向自己发起好友申请
+	ErrSelfRequest = errors.New("cannot send friend request to self")
+	// ErrRequestPending 已有待处理的好友申请
+	ErrRequestPending = errors.New("friend request already pending")
+	// ErrAlreadyFriends 已经是好友
+	ErrAlreadyFriends = errors.New("already friends")
+	// ErrRequestNotFound 好友申请不存在或已处理
+	ErrRequestNotFound = errors.New("friend request not found or already handled")
+)
+
+// friendRequests 好友申请表
+func (m *Model) friendRequests() *mongo.Collection {
+	return m.Context.Handler.Collection((&FriendRequest{}).CollectionName())
+}
+
+// RequestFriend 发起好友申请
+// 对方已向自己发起申请时直接接受对方的申请
+func (m *Model) RequestFriend(storeID, from, to, message string) (*FriendRequest, error) {
+	if from == to {
+		return nil, ErrSelfRequest
+	}
+	friends, err := m.IsFriend(storeID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if friends {
+		return nil, ErrAlreadyFriends
+	}
+
+	reverse := &FriendRequest{}
+	err = m.friendRequests().FindOne(m.Context.Context, bson.D{
+		{Key: "store_id", Value: storeID},
+		{Key: "from", Value: to},
+		{Key: "to", Value: from},
+		{Key: "status", Value: Pending},
+	}).Decode(reverse)
+	if err == nil {
+		return m.Accept(reverse.ID.Hex(), from)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	req := &FriendRequest{
+		ID:          primitive.NewObjectID(),
+		StoreID:     storeID,
+		From:        from,
+		To:          to,
+		Message:     message,
+		Status:      Pending,
+		RequestTime: time.Now().Unix(),
+	}
+	_, err = m.friendRequests().InsertOne(m.Context.Context, req)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrRequestPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Accept 接受好友申请并创建两人的会话，userID 必须是被申请人
+// 已接受但会话创建失败的申请可以再次接受，补建会话
+func (m *Model) Accept(requestID, userID string) (*FriendRequest, error) {
+	objID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, err
+	}
+	req, err := m.handle(acceptFilter(objID, userID), Accepted)
+	if err != nil {
+		return nil, err
+	}
+	conversation, err := m.getOrCreateDirect(req.StoreID, req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+	req.ConversationID = conversation.ID
+	_, err = m.friendRequests().UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: req.ID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "conversation_id", Value: conversation.ID}}}})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Reject 拒绝好友申请，userID 必须是被申请人
+func (m *Model) Reject(requestID, userID string) (*FriendRequest, error) {
+	objID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, err
+	}
+	return m.handle(bson.D{{Key: "_id", Value: objID}, {Key: "to", Value: userID}, {Key: "status", Value: Pending}}, Rejected)
+}
+
+// acceptFilter 被申请人可以接受的申请：待处理，或已接受但尚未创建会话
+func acceptFilter(requestID primitive.ObjectID, userID string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: requestID},
+		{Key: "to", Value: userID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "status", Value: Pending}},
+			bson.D{
+				{Key: "status", Value: Accepted},
+				{Key: "conversation_id", Value: bson.D{{Key: "$exists", Value: false}}},
+			},
+		}},
+	}
+}
+
+// handle 原子处理 filter 匹配的好友申请
+func (m *Model) handle(filter bson.D, to FriendRequestStatus) (*FriendRequest, error) {
+	req := &FriendRequest{}
+	err := m.friendRequests().FindOneAndUpdate(m.Context.Context, filter,
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: to},
+			{Key: "handle_time", Value: time.Now().Unix()},
+		}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(req)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// IsFriend 两个用户是否为好友（任一方向的申请已被接受）
+func (m *Model) IsFriend(storeID, a, b string) (bool, error) {
+	n, err := m.friendRequests().CountDocuments(m.Context.Context, bson.D{
+		{Key: "store_id", Value: storeID},
+		{Key: "status", Value: Accepted},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "from", Value: a}, {Key: "to", Value: b}},
+			bson.D{{Key: "from", Value: b}, {Key: "to", Value: a}},
+		}},
+	})
+	return n > 0, err
+}
+
+// FriendRequests 获取用户收到的好友申请，status 为空时返回全部，按申请时间倒序
+func (m *Model) FriendRequests(storeID, userID string, status FriendRequestStatus) ([]*FriendRequest, error) {
+	filter := bson.D{{Key: "store_id", Value: storeID}, {Key: "to", Value: userID}}
+	if status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: status})
+	}
+	results := make([]*FriendRequest, 0)
+	cursor, err := m.friendRequests().Find(m.Context.Context, filter,
+		options.Find().SetSort(bson.D{{Key: "request_time", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,367 @@
+package conversation
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultPageSize 默认每页数量
+	defaultPageSize = 20
+	// maxPageSize 每页最大数量
+	maxPageSize = 100
+)
+
+var (
+	// ErrNotParticipant 用户不是会话的参与者
+	ErrNotParticipant = errors.New("not a conversation participant")
+	// ErrEmptyMessage 消息内容为空
+	ErrEmptyMessage = errors.New("empty message")
+	// ErrSelfConversation 不能与自己创建会话
+	ErrSelfConversation = errors.New("cannot start a conversation with self")
+	// ErrNotFriends 只有好友之间才能创建会话
+	ErrNotFriends = errors.New("not friends")
+)
+
+// directKey 单聊会话键
+func directKey(storeID, a, b string) string {
+	users := []string{a, b}
+	sort.Strings(users)
+	return storeID + ":" + strings.Join(users, ":")
+}
+
+// GetOrCreateDirect 获取两个好友之间的会话，不存在时创建
+// 只有好友之间才能私信，非好友返回 ErrNotFriends
+func (m *Model) GetOrCreateDirect(storeID, a, b string) (*Model, error) {
+	if a == b {
+		return nil, ErrSelfConversation
+	}
+	friends, err := m.IsFriend(storeID, a, b)
+	if err != nil {
+		return nil, err
+	}
+	if !friends {
+		return nil, ErrNotFriends
+	}
+	return m.getOrCreateDirect(storeID, a, b)
+}
+
+// getOrCreateDirect 获取两个用户之间的会话，不存在时创建，调用方负责校验好友关系
+func (m *Model) getOrCreateDirect(storeID, a, b string) (*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	participants := []string{a, b}
+	sort.Strings(participants)
+	cursors := make([]*Cursor, 0, len(participants))
+	for _, user := range participants {
+		cursors = append(cursors, &Cursor{UserID: user})
+	}
+
+	result := &Model{}
+	err := coll.FindOneAndUpdate(m.Context.Context,
+		bson.D{{Key: "key", Value: directKey(storeID, a, b)}},
+		bson.D{{Key: "$setOnInsert", Value: bson.D{
+			{Key: "store_id", Value: storeID},
+			{Key: "participants", Value: participants},
+			{Key: "cursors", Value: cursors},
+			{Key: "seq", Value: 0},
+			{Key: "created_time", Value: time.Now().Unix()},
+		}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(result)
+	if mongo.IsDuplicateKeyError(err) {
+		// 并发创建，由唯一索引保证只有一个会话
+		err = coll.FindOne(m.Context.Context, bson.D{{Key: "key", Value: directKey(storeID, a, b)}}).Decode(result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// messenger 会话消息的存储
+type messenger interface {
+	// bump 递增会话序号并记录最新消息，返回更新前的会话，sender 不是参与者时返回 ErrNotParticipant
+	bump(conversationID primitive.ObjectID, sender, content string, now int64) (*Model, error)
+	// unbump 将会话恢复为 previous，仅当会话序号仍为 seq 时生效
+	unbump(previous *Model, seq int64) error
+	// insert 写入消息
+	insert(msg *Message) error
+}
+
+// Send 发送消息，sender 必须是会话的参与者
+// 会话序号原子递增，发送者的已读位置同步到该消息
+func (m *Model) Send(conversationID, sender, content string) (*Message, error) {
+	msg, err := send(m, conversationID, sender, content, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if err = m.MarkRead(conversationID, sender, msg.Seq); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// send 分配序号并写入消息，写入失败时恢复会话的序号与最新消息
+func send(s messenger, conversationID, sender, content string, now int64) (*Message, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrEmptyMessage
+	}
+	objID, err := primitive.ObjectIDFromHex(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.bump(objID, sender, content, now)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		ID:             primitive.NewObjectID(),
+		ConversationID: objID,
+		Seq:            previous.Seq + 1,
+		Sender:         sender,
+		Content:        content,
+		SendTime:       now,
+	}
+	if err = s.insert(msg); err != nil {
+		if rollbackErr := s.unbump(previous, msg.Seq); rollbackErr != nil {
+			return nil, errors.Join(err, rollbackErr)
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// bump 递增会话序号并记录最新消息，返回更新前的会话
+func (m *Model) bump(conversationID primitive.ObjectID, sender, content string, now int64) (*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	previous := &Model{}
+	err := coll.FindOneAndUpdate(m.Context.Context,
+		bson.D{{Key: "_id", Value: conversationID}, {Key: "participants", Value: sender}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "seq", Value: 1}}},
+			{Key: "$set", Value: bson.D{
+				{Key: "last_sender", Value: sender},
+				{Key: "last_content", Value: content},
+				{Key: "last_message_time", Value: now},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotParticipant
+	}
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// unbump 将会话恢复为 previous，期间已有新消息（序号不为 seq）时不恢复
+func (m *Model) unbump(previous *Model, seq int64) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: previous.ID}, {Key: "seq", Value: seq}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "seq", Value: previous.Seq},
+			{Key: "last_sender", Value: previous.LastSender},
+			{Key: "last_content", Value: previous.LastContent},
+			{Key: "last_message_time", Value: previous.LastMessageTime},
+		}}})
+	return err
+}
+
+// insert 写入消息
+func (m *Model) insert(msg *Message) error {
+	_, err := m.Context.Handler.Collection(msg.CollectionName()).InsertOne(m.Context.Context, msg)
+	return err
+}
+
+// MarkRead 将用户在会话中的已读位置推进到 seq，不会回退，也不会超过会话的最新消息
+func (m *Model) MarkRead(conversationID, userID string, seq int64) error {
+	return m.markRead(conversationID, userID, bson.D{{Key: "$min", Value: bson.A{seq, "$seq"}}})
+}
+
+// MarkAllRead 将用户在会话中的已读位置推进到最新消息
+func (m *Model) MarkAllRead(conversationID, userID string) error {
+	return m.markRead(conversationID, userID, "$seq")
+}
+
+// markRead 使用更新管道推进已读位置，target 为目标序号的表达式（可引用会话的 $seq）
+func (m *Model) markRead(conversationID, userID string, target interface{}) error {
+	objID, err := primitive.ObjectIDFromHex(conversationID)
+	if err != nil {
+		return err
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "cursors", Value: bson.D{{Key: "$map", Value: bson.D{
+		{Key: "input", Value: "$cursors"},
+		{Key: "as", Value: "c"},
+		{Key: "in", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{"$$c.user_id", userID}}},
+			bson.D{
+				{Key: "user_id", Value: "$$c.user_id"},
+				{Key: "seq", Value: bson.D{{Key: "$max", Value: bson.A{"$$c.seq", target}}}},
+			},
+			"$$c",
+		}}}},
+	}}}}}}}}
+	result, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: objID}, {Key: "cursors.user_id", Value: userID}},
+		update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotParticipant
+	}
+	return nil
+}
+
+// List 分页获取用户的会话，按最新消息时间倒序
+func (m *Model) List(userID string, page, pageSize int64) ([]*Item, int64, error) {
+	page, pageSize = paginate(page, pageSize)
+	conversations := make([]*Model, 0)
+	opt := options.Find().
+		SetSort(bson.D{{Key: "last_message_time", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+	total, err := m.GetListWithOpt(bson.D{{Key: "participants", Value: userID}}, &conversations, opt)
+	if err != nil {
+		return nil, total, err
+	}
+	results := make([]*Item, 0, len(conversations))
+	for _, c := range conversations {
+		results = append(results, &Item{Model: c, Unread: c.Unread(userID)})
+	}
+	return results, total, nil
+}
+
+// Unread 用户在会话中的未读消息数
+func (m *Model) Unread(userID string) int64 {
+	for _, c := range m.Cursors {
+		if c.UserID == userID {
+			if c.Seq >= m.Seq {
+				return 0
+			}
+			return m.Seq - c.Seq
+		}
+	}
+	return 0
+}
+
+// UnreadCount 用户所有会话的未读消息总数
+func (m *Model) UnreadCount(userID string) (int64, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "participants", Value: userID}}}},
+		bson.D{{Key: "$unwind", Value: "$cursors"}},
+		bson.D{{Key: "$match", Value: bson.D{{Key: "cursors.user_id", Value: userID}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$max", Value: bson.A{
+				0, bson.D{{Key: "$subtract", Value: bson.A{"$seq", "$cursors.seq"}}},
+			}}}}}},
+		}}},
+	}
+	cursor, err := coll.Aggregate(m.Context.Context, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	rows := make([]struct {
+		Count int64 `bson:"count"`
+	}, 0)
+	if err = cursor.All(m.Context.Context, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].Count, nil
+}
+
+// Messages 获取会话中序号小于 before 的消息（before 为 0 时从最新开始），按序号倒序
+func (m *Model) Messages(conversationID, userID string, before int64, limit int64) ([]*Message, error) {
+	objID, err := primitive.ObjectIDFromHex(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	n, err := coll.CountDocuments(m.Context.Context, bson.D{{Key: "_id", Value: objID}, {Key: "participants", Value: userID}})
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNotParticipant
+	}
+
+	_, limit = paginate(1, limit)
+	filter := bson.D{{Key: "conversation_id", Value: objID}}
+	if before > 0 {
+		filter = append(filter, bson.E{Key: "seq", Value: bson.D{{Key: "$lt", Value: before}}})
+	}
+	results := make([]*Message, 0)
+	cursor, err := m.Context.Handler.Collection((&Message{}).CollectionName()).Find(m.Context.Context, filter,
+		options.Find().SetSort(bson.D{{Key: "seq", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// paginate 规整分页参数
+func paginate(page, pageSize int64) (int64, int64) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+// CreateIndexes 创建会话、消息与好友申请的索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateMany(m.Context.Context, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(
+				bson.D{{Key: "key", Value: bson.D{{Key: "$exists", Value: true}}}}),
+		},
+		{Keys: bson.D{{Key: "participants", Value: 1}, {Key: "last_message_time", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.Context.Handler.Collection((&Message{}).CollectionName()).Indexes().CreateOne(m.Context.Context, mongo.IndexModel{
+		Keys:    bson.D{{Key: "conversation_id", Value: 1}, {Key: "seq", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.Context.Handler.Collection((&FriendRequest{}).CollectionName()).Indexes().CreateMany(m.Context.Context, []mongo.IndexModel{
+		{
+			// 两人之间同一方向只能有一个待处理的申请
+			Keys: bson.D{{Key: "store_id", Value: 1}, {Key: "from", Value: 1}, {Key: "to", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(
+				bson.D{{Key: "status", Value: Pending}}),
+		},
+		{Keys: bson.D{{Key: "to", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	return err
+}
@@ -0,0 +1,155 @@
+package conversation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memMessenger 内存中的会话与消息
+type memMessenger struct {
+	conversation *Model
+	messages     []*Message
+	fail         bool
+}
+
+func (s *memMessenger) bump(conversationID primitive.ObjectID, sender, content string, now int64) (*Model, error) {
+	c := s.conversation
+	if c.ID != conversationID {
+		return nil, ErrNotParticipant
+	}
+	for _, p := range c.Participants {
+		if p == sender {
+			previous := *c
+			c.Seq++
+			c.LastSender, c.LastContent, c.LastMessageTime = sender, content, now
+			return &previous, nil
+		}
+	}
+	return nil, ErrNotParticipant
+}
+
+func (s *memMessenger) unbump(previous *Model, seq int64) error {
+	c := s.conversation
+	if c.Seq != seq {
+		return nil
+	}
+	c.Seq = previous.Seq
+	c.LastSender, c.LastContent, c.LastMessageTime = previous.LastSender, previous.LastContent, previous.LastMessageTime
+	return nil
+}
+
+func (s *memMessenger) insert(msg *Message) error {
+	if s.fail {
+		return errors.New("insert failed")
+	}
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	id := primitive.NewObjectID()
+	tests := []struct {
+		name        string
+		sender      string
+		content     string
+		fail        bool
+		wantErr     bool
+		wantSeq     int64
+		wantContent string
+	}{
+		{"sent", "u1", "hi", false, false, 4, "hi"},
+		{"empty", "u1", "  ", false, true, 3, "hello"},
+		{"not participant", "u3", "hi", false, true, 3, "hello"},
+		{"insert failure rolls back", "u1", "hi", true, true, 3, "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &memMessenger{
+				conversation: &Model{ID: id, Participants: []string{"u1", "u2"}, Seq: 3, LastSender: "u2", LastContent: "hello", LastMessageTime: 100},
+				fail:         tt.fail,
+			}
+			msg, err := send(s, id.Hex(), tt.sender, tt.content, 200)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if s.conversation.Seq != tt.wantSeq || s.conversation.LastContent != tt.wantContent {
+				t.Errorf("conversation = seq %d %q, want seq %d %q",
+					s.conversation.Seq, s.conversation.LastContent, tt.wantSeq, tt.wantContent)
+			}
+			if err == nil && (msg.Seq != tt.wantSeq || len(s.messages) != 1) {
+				t.Errorf("message seq = %d (%d stored), want %d", msg.Seq, len(s.messages), tt.wantSeq)
+			}
+			if err != nil && len(s.messages) != 0 {
+				t.Errorf("stored %d messages after error", len(s.messages))
+			}
+		})
+	}
+}
+
+func TestSendRollbackAfterNewMessage(t *testing.T) {
+	id := primitive.NewObjectID()
+	s := &memMessenger{conversation: &Model{ID: id, Participants: []string{"u1", "u2"}, Seq: 3}}
+	previous, _ := s.bump(id, "u1", "first", 100)
+	// 另一条消息在回滚前已占用下一个序号
+	s.bump(id, "u2", "second", 101)
+	if err := s.unbump(previous, previous.Seq+1); err != nil {
+		t.Fatal(err)
+	}
+	if s.conversation.Seq != 5 || s.conversation.LastContent != "second" {
+		t.Errorf("conversation = seq %d %q, want seq 5 %q", s.conversation.Seq, s.conversation.LastContent, "second")
+	}
+}
+
+func TestGetOrCreateDirectSelf(t *testing.T) {
+	if _, err := (&Model{}).GetOrCreateDirect("s1", "u1", "u1"); !errors.Is(err, ErrSelfConversation) {
+		t.Errorf("GetOrCreateDirect() error = %v, want %v", err, ErrSelfConversation)
+	}
+}
+
+func TestDirectKey(t *testing.T) {
+	if directKey("s1", "u2", "u1") != directKey("s1", "u1", "u2") {
+		t.Error("directKey() depends on the participant order")
+	}
+	if directKey("s1", "u1", "u2") == directKey("s2", "u1", "u2") {
+		t.Error("directKey() ignores the store")
+	}
+}
+
+func TestAcceptFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+	want := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "to", Value: "u2"},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "status", Value: Pending}},
+			bson.D{
+				{Key: "status", Value: Accepted},
+				{Key: "conversation_id", Value: bson.D{{Key: "$exists", Value: false}}},
+			},
+		}},
+	}
+	if got := acceptFilter(id, "u2"); !reflect.DeepEqual(got, want) {
+		t.Errorf("acceptFilter() = %v, want %v", got, want)
+	}
+}
+
+func TestUnread(t *testing.T) {
+	c := &Model{Seq: 5, Cursors: []*Cursor{{UserID: "u1", Seq: 2}, {UserID: "u2", Seq: 7}}}
+	tests := []struct {
+		user string
+		want int64
+	}{
+		{"u1", 3},
+		{"u2", 0},
+		{"u3", 0},
+	}
+	for _, tt := range tests {
+		if got := c.Unread(tt.user); got != tt.want {
+			t.Errorf("Unread(%q) = %d, want %d", tt.user, got, tt.want)
+		}
+	}
+}
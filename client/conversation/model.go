@@ -0,0 +1,144 @@
+package conversation
+
+import (
+	"github.com/open4go/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "client_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_flow"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "conversation"
+	// messageName 会话消息
+	messageName = "conversation_message"
+	// friendRequestName 好友申请
+	friendRequestName = "friend_request"
+)
+
+// Cursor 参与者的已读位置
+type Cursor struct {
+	// UserID 用户
+	UserID string `json:"user_id" bson:"user_id"`
+	// Seq 已读到的消息序号
+	Seq int64 `json:"seq" bson:"seq"`
+}
+
+// Model 会话（私信）
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// StoreID 门店
+	StoreID string `json:"store_id" bson:"store_id,omitempty"`
+	// Key 会话键，单聊为排序后的参与者，保证两人之间只有一个会话
+	Key string `json:"key" bson:"key,omitempty"`
+	// Participants 参与者
+	Participants []string `json:"participants" bson:"participants"`
+	// Cursors 各参与者的已读位置
+	Cursors []*Cursor `json:"cursors" bson:"cursors"`
+	// Seq 最新消息序号
+	Seq int64 `json:"seq" bson:"seq"`
+	// LastSender 最新消息发送者
+	LastSender string `json:"last_sender" bson:"last_sender,omitempty"`
+	// LastContent 最新消息内容
+	LastContent string `json:"last_content" bson:"last_content,omitempty"`
+	// LastMessageTime 最新消息时间
+	LastMessageTime int64 `json:"last_message_time" bson:"last_message_time,omitempty"`
+	// CreatedTime 创建时间
+	CreatedTime int64 `json:"created_time" bson:"created_time,omitempty"`
+}
+
+// Item 用户会话列表中的一项
+type Item struct {
+	*Model
+	// Unread 未读消息数
+	Unread int64 `json:"unread"`
+}
+
+// Message 会话消息
+type Message struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// ConversationID 会话
+	ConversationID primitive.ObjectID `json:"conversation_id" bson:"conversation_id"`
+	// Seq 会话内的消息序号，从 1 开始递增
+	Seq int64 `json:"seq" bson:"seq"`
+	// Sender 发送者
+	Sender string `json:"sender" bson:"sender"`
+	// Content 内容
+	Content string `json:"content" bson:"content"`
+	// SendTime 发送时间
+	SendTime int64 `json:"send_time" bson:"send_time"`
+}
+
+// FriendRequestStatus 好友申请状态
+type FriendRequestStatus string
+
+const (
+	// Pending 待处理
+	Pending FriendRequestStatus = "pending"
+	// Accepted 已接受
+	Accepted FriendRequestStatus = "accepted"
+	// Rejected 已拒绝
+	Rejected FriendRequestStatus = "rejected"
+)
+
+// FriendRequest 好友申请
+type FriendRequest struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// StoreID 门店
+	StoreID string `json:"store_id" bson:"store_id,omitempty"`
+	// From 申请人
+	From string `json:"from" bson:"from"`
+	// To 被申请人
+	To string `json:"to" bson:"to"`
+	// Message 附言
+	Message string `json:"message" bson:"message,omitempty"`
+	// Status 状态
+	Status FriendRequestStatus `json:"status" bson:"status"`
+	// ConversationID 接受后创建的会话
+	ConversationID primitive.ObjectID `json:"conversation_id" bson:"conversation_id,omitempty"`
+	// RequestTime 申请时间
+	RequestTime int64 `json:"request_time" bson:"request_time"`
+	// HandleTime 处理时间
+	HandleTime int64 `json:"handle_time" bson:"handle_time,omitempty"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
+
+// CollectionName 返回表名称
+func (m *Message) CollectionName() string {
+	return collectionNamePrefix + messageName + collectionNameSuffix
+}
+
+// CollectionName 返回表名称
+func (m *FriendRequest) CollectionName() string {
+	return collectionNamePrefix + friendRequestName + collectionNameSuffix
+}
@@ -0,0 +1,235 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/open4go/log"
+	"github.com/r2day/m3s/client/inbox"
+	"github.com/r2day/m3s/client/notification"
+	"github.com/r2day/m3s/message/quota"
+	"github.com/r2day/m3s/message/subscribe"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// day 一天
+	day = 24 * time.Hour
+	// defaultPeriod 默认摘要周期
+	defaultPeriod = day
+	// dateLayout 摘要时间格式
+	dateLayout = "2006-01-02"
+)
+
+// Fields 摘要订阅消息模版的关键词
+type Fields struct {
+	// Count 未读数量（number）
+	Count string
+	// Title 最重要的一条通知标题（thing）
+	Title string
+	// Date 摘要日期（date）
+	Date string
+}
+
+// Result 一次摘要生成的结果
+type Result struct {
+	// Users 处理的用户数
+	Users int `json:"users"`
+	// Enqueued 写入发件箱的摘要数
+	Enqueued int `json:"enqueued"`
+	// Empty 没有未读通知的用户数
+	Empty int `json:"empty"`
+	// NoQuota 没有订阅额度的用户数
+	NoQuota int `json:"no_quota"`
+	// Failed 失败的用户数
+	Failed int `json:"failed"`
+}
+
+// Digester 通知摘要
+// 将用户在周期内未读的低优先级通知汇总为一条订阅消息，经订阅消息发件箱投递
+type Digester struct {
+	// DB 数据库
+	DB *mongo.Database
+	// TemplateID 摘要使用的订阅消息模版
+	TemplateID string
+	// Page 点击摘要跳转的小程序页面
+	Page string
+	// Fields 模版关键词
+	Fields Fields
+	// MaxPriority 优先级不高于该值的通知计入摘要
+	MaxPriority int
+	// Period 摘要周期，需要整除一天或为整数天，为 0 时使用一天
+	Period time.Duration
+	// Location 按该时区划分摘要周期，为空时使用本地时区
+	Location *time.Location
+}
+
+// ErrPeriod 摘要周期无效
+var ErrPeriod = errors.New("digest period must divide a day or be whole days")
+
+// NewDigester 创建通知摘要
+func NewDigester(db *mongo.Database, templateID, page string, fields Fields) *Digester {
+	return &Digester{
+		DB:         db,
+		TemplateID: templateID,
+		Page:       page,
+		Fields:     fields,
+		Period:     defaultPeriod,
+	}
+}
+
+// Run 为门店所有未退订的用户生成摘要
+// 同一用户同一周期只会生成一条摘要（通过订阅消息的幂等键保证），可重复执行
+func (d *Digester) Run(ctx context.Context, storeID string, now time.Time) (*Result, error) {
+	if _, err := d.period(); err != nil {
+		return nil, err
+	}
+	pref := &Model{}
+	pref.Init(ctx, d.DB, pref.CollectionName())
+	coll := d.DB.Collection(pref.CollectionName())
+	cursor, err := coll.Find(ctx, bson.D{
+		{Key: "store_id", Value: storeID},
+		{Key: "opt_out", Value: false},
+		{Key: "receiver", Value: bson.D{{Key: "$gt", Value: ""}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := &Result{}
+	for cursor.Next(ctx) {
+		user := &Model{}
+		if err = cursor.Decode(user); err != nil {
+			return result, err
+		}
+		result.Users++
+		enqueued, err := d.digest(ctx, user, now)
+		switch {
+		case errors.Is(err, quota.ErrNoQuota):
+			result.NoQuota++
+		case err != nil:
+			result.Failed++
+			log.Log(ctx).WithField("user_id", user.UserID).Error(err)
+		case enqueued:
+			result.Enqueued++
+		default:
+			result.Empty++
+		}
+	}
+	return result, cursor.Err()
+}
+
+// period 摘要周期，为 0 时使用默认周期
+func (d *Digester) period() (time.Duration, error) {
+	if d.Period == 0 {
+		return defaultPeriod, nil
+	}
+	if d.Period < 0 || (day%d.Period != 0 && d.Period%day != 0) {
+		return 0, fmt.Errorf("%w: %s", ErrPeriod, d.Period)
+	}
+	return d.Period, nil
+}
+
+func (d *Digester) location() *time.Location {
+	if d.Location == nil {
+		return time.Local
+	}
+	return d.Location
+}
+
+// window now 所在摘要周期的开始时间，按时区的自然日划分
+// 不足一天的周期从当天零点起算，整数天的周期按自然日编号对齐
+func (d *Digester) window(now time.Time) (time.Time, error) {
+	period, err := d.period()
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := d.location()
+	now = now.In(loc)
+	year, month, date := now.Date()
+	start := time.Date(year, month, date, 0, 0, 0, 0, loc)
+	if period < day {
+		return start.Add(now.Sub(start).Truncate(period)), nil
+	}
+	days := int64(period / day)
+	n := time.Date(year, month, date, 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+	return start.AddDate(0, 0, -int(n%days)), nil
+}
+
+// digest 为用户生成一条摘要，没有未读通知时返回 false
+func (d *Digester) digest(ctx context.Context, user *Model, now time.Time) (bool, error) {
+	period, err := d.period()
+	if err != nil {
+		return false, err
+	}
+	start, err := d.window(now)
+	if err != nil {
+		return false, err
+	}
+	since := now.Add(-period).Unix()
+	if user.LastDigestTime > since {
+		since = user.LastDigestTime
+	}
+
+	box := &inbox.Model{}
+	box.Init(ctx, d.DB, box.CollectionName())
+	// 摘要只知道用户身份与渠道，依赖会员等级、标签等条件的定向通知不会计入摘要
+	u := &notification.UserContext{UserID: user.UserID, AppType: user.Channel}
+	items, err := box.Unread(user.StoreID, u, bson.D{
+		{Key: "publish_time", Value: bson.D{{Key: "$gt", Value: since}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "priority", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "priority", Value: bson.D{{Key: "$lte", Value: d.MaxPriority}}}},
+		}},
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(items) == 0 {
+		return false, nil
+	}
+
+	msg := &subscribe.Model{
+		TemplateID:     d.TemplateID,
+		Channel:        user.Channel,
+		Receiver:       user.Receiver,
+		Page:           d.Page,
+		Payload:        d.payload(items, user, now),
+		IdempotencyKey: fmt.Sprintf("digest:%s:%s:%d", user.StoreID, user.UserID, start.Unix()),
+		CorrelationKey: "digest:" + user.UserID,
+	}
+	msg.Init(ctx, d.DB, msg.CollectionName())
+	if _, err = msg.Enqueue(); err != nil {
+		return false, err
+	}
+
+	// 摘要已入队，记录时间失败不影响结果：同一周期重复执行时由幂等键去重
+	pref := &Model{}
+	_, err = d.DB.Collection(pref.CollectionName()).UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: user.ID}},
+		bson.D{{Key: "$max", Value: bson.D{{Key: "last_digest_time", Value: now.Unix()}}}})
+	if err != nil {
+		log.Log(ctx).WithField("user_id", user.UserID).Error(err)
+	}
+	return true, nil
+}
+
+// payload 摘要消息内容，标题使用优先级最高的一条通知
+func (d *Digester) payload(items []*notification.Model, user *Model, now time.Time) subscribe.Payload {
+	payload := subscribe.Payload{}
+	if d.Fields.Count != "" {
+		payload[d.Fields.Count] = strconv.Itoa(len(items))
+	}
+	if d.Fields.Title != "" {
+		payload[d.Fields.Title] = items[0].Title.Get(user.Locale)
+	}
+	if d.Fields.Date != "" {
+		payload[d.Fields.Date] = now.In(d.location()).Format(dateLayout)
+	}
+	return payload
+}
@@ -0,0 +1,72 @@
+package digest
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDigesterPeriod(t *testing.T) {
+	tests := []struct {
+		period time.Duration
+		want   time.Duration
+		err    bool
+	}{
+		{0, defaultPeriod, false},
+		{time.Hour, time.Hour, false},
+		{6 * time.Hour, 6 * time.Hour, false},
+		{48 * time.Hour, 48 * time.Hour, false},
+		{-time.Hour, 0, true},
+		{time.Second * 7, 0, true},
+		{5 * time.Hour, 0, true},
+		{36 * time.Hour, 0, true},
+	}
+	for _, tt := range tests {
+		d := &Digester{Period: tt.period}
+		got, err := d.period()
+		if tt.err {
+			if !errors.Is(err, ErrPeriod) {
+				t.Errorf("period(%s) error = %v, want %v", tt.period, err, ErrPeriod)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("period(%s) = %s, %v, want %s", tt.period, got, err, tt.want)
+		}
+	}
+}
+
+func TestDigesterWindow(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	// 2024-03-10 01:30 +08:00，UTC 仍为前一天
+	now := time.Date(2024, 3, 10, 1, 30, 0, 0, shanghai)
+
+	tests := []struct {
+		name   string
+		period time.Duration
+		want   time.Time
+	}{
+		{"day", 0, time.Date(2024, 3, 10, 0, 0, 0, 0, shanghai)},
+		{"hours", 6 * time.Hour, time.Date(2024, 3, 10, 0, 0, 0, 0, shanghai)},
+		{"hour", time.Hour, time.Date(2024, 3, 10, 1, 0, 0, 0, shanghai)},
+		{"two days", 48 * time.Hour, time.Date(2024, 3, 10, 0, 0, 0, 0, shanghai)},
+		{"three days", 72 * time.Hour, time.Date(2024, 3, 9, 0, 0, 0, 0, shanghai)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Digester{Period: tt.period, Location: shanghai}
+			got, err := d.window(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("window() = %s, want %s", got, tt.want)
+			}
+			// 同一周期内的时间得到相同的开始时间
+			later, _ := d.window(now.Add(tt.want.Sub(now) + time.Minute))
+			if !later.Equal(got) {
+				t.Fatalf("window() = %s, want %s", later, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package digest
+
+import (
+	"errors"
+	"time"
+
+	home "github.com/r2day/m3s/client/home"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Subscribe 登记或更新用户的摘要接收渠道，并恢复订阅
+func (m *Model) Subscribe(storeID, userID string, channel home.AppType, receiver, locale string) error {
+	return m.upsert(storeID, userID, bson.D{
+		{Key: "channel", Value: channel},
+		{Key: "receiver", Value: receiver},
+		{Key: "locale", Value: locale},
+		{Key: "opt_out", Value: false},
+	})
+}
+
+// SetOptOut 设置用户是否退订摘要
+func (m *Model) SetOptOut(storeID, userID string, optOut bool) error {
+	return m.upsert(storeID, userID, bson.D{{Key: "opt_out", Value: optOut}})
+}
+
+// GetByUser 获取用户的摘要设置
+func (m *Model) GetByUser(storeID, userID string) (*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	result := &Model{}
+	err := coll.FindOne(m.Context.Context, bson.D{
+		{Key: "store_id", Value: storeID},
+		{Key: "user_id", Value: userID},
+	}).Decode(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// OptedOut 用户是否退订了摘要，未设置时视为未退订
+func (m *Model) OptedOut(storeID, userID string) (bool, error) {
+	result, err := m.GetByUser(storeID, userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return result.OptOut, nil
+}
+
+func (m *Model) upsert(storeID, userID string, set bson.D) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	set = append(set, bson.E{Key: "updated_time", Value: time.Now().Unix()})
+	_, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "store_id", Value: storeID}, {Key: "user_id", Value: userID}},
+		bson.D{{Key: "$set", Value: set}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// CreateIndexes 创建用户唯一索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateOne(m.Context.Context, mongo.IndexModel{
+		Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,57 @@
+package digest
+
+import (
+	"github.com/open4go/model"
+	home "github.com/r2day/m3s/client/home"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "client_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_config"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "digest"
+)
+
+// Model 用户的通知摘要设置
+// 记录摘要发送的渠道与接收者，以及用户是否退订
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// StoreID 门店
+	StoreID string `json:"store_id" bson:"store_id"`
+	// UserID 用户
+	UserID string `json:"user_id" bson:"user_id"`
+	// Channel 订阅消息渠道
+	Channel home.AppType `json:"channel" bson:"channel"`
+	// Receiver 渠道的 openid/用户号
+	Receiver string `json:"receiver" bson:"receiver,omitempty"`
+	// Locale 摘要使用的语言
+	Locale string `json:"locale" bson:"locale,omitempty"`
+	// OptOut 是否退订摘要
+	OptOut bool `json:"opt_out" bson:"opt_out"`
+	// LastDigestTime 上次生成摘要的时间
+	LastDigestTime int64 `json:"last_digest_time" bson:"last_digest_time,omitempty"`
+	// UpdatedTime 设置更新时间
+	UpdatedTime int64 `json:"updated_time" bson:"updated_time,omitempty"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
@@ -0,0 +1,196 @@
+package engagement
+
+import (
+	"errors"
+	"time"
+
+	"github.com/open4go/log"
+	"github.com/r2day/m3s/client/notification"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUnknownEvent 未知的互动事件
+var ErrUnknownEvent = errors.New("unknown engagement event")
+
+// RecordOptions 记录选项
+type RecordOptions struct {
+	// SkipDaily 不记录每日明细
+	SkipDaily bool
+}
+
+// recorder 互动记录的存储
+type recorder interface {
+	// markSeen 记录用户当天的事件，已记录过时返回 false
+	markSeen(id string, now time.Time) (bool, error)
+	// unmarkSeen 撤销用户当天的事件记录
+	unmarkSeen(id string)
+	// count 计入通知的互动总数
+	count(event Event, notificationID string) error
+	// daily 计入每日明细
+	daily(event Event, notificationID, day string, unique bool) error
+}
+
+// Record 使用默认选项记录互动，见 RecordWith
+func (m *Model) Record(event Event, notificationID, userID string, now time.Time) (bool, error) {
+	return m.RecordWith(event, notificationID, userID, now, nil)
+}
+
+// RecordWith 记录用户对通知的一次互动，opt 为空时使用默认选项
+// 按 now 所在时区划分自然日，同一用户同一天对同一通知的相同事件只计入通知一次，返回是否为当天首次；userID 为空时不去重
+// 计入通知失败时撤销当天的去重记录，以便重试时仍能计入
+func (m *Model) RecordWith(event Event, notificationID, userID string, now time.Time, opt *RecordOptions) (bool, error) {
+	return record(m, event, notificationID, userID, now, opt)
+}
+
+func record(r recorder, event Event, notificationID, userID string, now time.Time, opt *RecordOptions) (bool, error) {
+	if event != Impression && event != Click {
+		return false, ErrUnknownEvent
+	}
+	if _, err := primitive.ObjectIDFromHex(notificationID); err != nil {
+		return false, err
+	}
+	day := now.Format(dayLayout)
+
+	unique := true
+	seenID := ""
+	if userID != "" {
+		seenID = string(event) + ":" + notificationID + ":" + userID + ":" + day
+		var err error
+		unique, err = r.markSeen(seenID, now)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if unique {
+		if err := r.count(event, notificationID); err != nil {
+			if seenID != "" {
+				r.unmarkSeen(seenID)
+			}
+			return false, err
+		}
+	}
+
+	if opt != nil && opt.SkipDaily {
+		return unique, nil
+	}
+	return unique, r.daily(event, notificationID, day, unique)
+}
+
+// count 计入通知的互动总数
+func (m *Model) count(event Event, notificationID string) error {
+	n := &notification.Model{}
+	n.Init(m.Context.Context, m.Context.Handler, n.CollectionName())
+	if event == Click {
+		return n.IncClick(notificationID, 1)
+	}
+	return n.IncImpression(notificationID, 1)
+}
+
+// daily 计入每日明细
+func (m *Model) daily(event Event, notificationID, day string, unique bool) error {
+	inc := bson.D{{Key: string(event) + "s", Value: 1}}
+	if unique {
+		inc = append(inc, bson.E{Key: "unique_" + string(event) + "s", Value: 1})
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: notificationID + ":" + day}},
+		bson.D{
+			{Key: "$inc", Value: inc},
+			{Key: "$setOnInsert", Value: bson.D{
+				{Key: "notification_id", Value: notificationID},
+				{Key: "day", Value: day},
+			}},
+		},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// markSeen 记录用户当天的事件，已记录过时返回 false
+func (m *Model) markSeen(id string, now time.Time) (bool, error) {
+	year, month, date := now.Date()
+	coll := m.Context.Handler.Collection(m.SeenCollectionName())
+	_, err := coll.InsertOne(m.Context.Context, &seen{
+		ID:       id,
+		ExpireAt: time.Date(year, month, date+1, 0, 0, 0, 0, now.Location()),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// unmarkSeen 撤销用户当天的事件记录
+func (m *Model) unmarkSeen(id string) {
+	coll := m.Context.Handler.Collection(m.SeenCollectionName())
+	if _, err := coll.DeleteOne(m.Context.Context, bson.D{{Key: "_id", Value: id}}); err != nil {
+		log.Log(m.Context.Context).WithField("seen", id).Error(err)
+	}
+}
+
+// GetDaily 获取通知在 [from, to] 日期范围内的每日明细，按日期升序；日期按 from、to 所在时区计算
+func (m *Model) GetDaily(notificationID string, from, to time.Time) ([]*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{
+		{Key: "notification_id", Value: notificationID},
+		{Key: "day", Value: bson.D{
+			{Key: "$gte", Value: from.Format(dayLayout)},
+			{Key: "$lte", Value: to.Format(dayLayout)},
+		}},
+	}
+	results := make([]*Model, 0)
+	cursor, err := coll.Find(m.Context.Context, filter, options.Find().SetSort(bson.D{{Key: "day", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Report 获取通知的点击率报表，包含 [from, to] 日期范围内的每日明细
+func (m *Model) Report(notificationID string, from, to time.Time) (*Report, error) {
+	n := &notification.Model{}
+	n.Init(m.Context.Context, m.Context.Handler, n.CollectionName())
+	if err := n.GetOne(n, notificationID); err != nil {
+		return nil, err
+	}
+	days, err := m.GetDaily(notificationID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		NotificationID: notificationID,
+		Impressions:    n.ImpressionCount,
+		Clicks:         n.ClickCount,
+		Days:           days,
+	}
+	if report.Impressions > 0 {
+		report.CTR = float64(report.Clicks) / float64(report.Impressions)
+	}
+	return report, nil
+}
+
+// CreateIndexes 创建每日明细查询索引及去重记录的 TTL 索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateOne(m.Context.Context, mongo.IndexModel{
+		Keys: bson.D{{Key: "notification_id", Value: 1}, {Key: "day", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.Context.Handler.Collection(m.SeenCollectionName()).Indexes().CreateOne(m.Context.Context, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expire_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
@@ -0,0 +1,152 @@
+package engagement
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memRecorder 内存中的互动记录
+type memRecorder struct {
+	seen   map[string]bool
+	counts map[Event]int
+	days   map[string]int
+	unique map[string]int
+	fail   bool
+}
+
+func newMemRecorder() *memRecorder {
+	return &memRecorder{
+		seen:   map[string]bool{},
+		counts: map[Event]int{},
+		days:   map[string]int{},
+		unique: map[string]int{},
+	}
+}
+
+func (r *memRecorder) markSeen(id string, now time.Time) (bool, error) {
+	if r.seen[id] {
+		return false, nil
+	}
+	r.seen[id] = true
+	return true, nil
+}
+
+func (r *memRecorder) unmarkSeen(id string) {
+	delete(r.seen, id)
+}
+
+func (r *memRecorder) count(event Event, notificationID string) error {
+	if r.fail {
+		return errors.New("notification unavailable")
+	}
+	r.counts[event]++
+	return nil
+}
+
+func (r *memRecorder) daily(event Event, notificationID, day string, unique bool) error {
+	r.days[string(event)+":"+day]++
+	if unique {
+		r.unique[string(event)+":"+day]++
+	}
+	return nil
+}
+
+func TestRecordDedup(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	day := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		event  Event
+		user   string
+		at     time.Time
+		unique bool
+	}{
+		{"first click", Click, "u1", day, true},
+		{"same day", Click, "u1", day.Add(time.Hour), false},
+		{"other event", Impression, "u1", day, true},
+		{"other user", Click, "u2", day, true},
+		{"next day", Click, "u1", day.Add(24 * time.Hour), true},
+		{"anonymous", Click, "", day, true},
+		{"anonymous again", Click, "", day, true},
+	}
+	r := newMemRecorder()
+	for _, tt := range tests {
+		unique, err := record(r, tt.event, id, tt.user, tt.at, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if unique != tt.unique {
+			t.Errorf("%s: unique = %v, want %v", tt.name, unique, tt.unique)
+		}
+	}
+	if r.counts[Click] != 5 || r.counts[Impression] != 1 {
+		t.Errorf("counts = %v, want 5 clicks and 1 impression", r.counts)
+	}
+	if r.days["click:2024-01-01"] != 5 || r.unique["click:2024-01-01"] != 4 {
+		t.Errorf("daily = %d (%d unique), want 5 (4 unique)", r.days["click:2024-01-01"], r.unique["click:2024-01-01"])
+	}
+}
+
+func TestRecordUndoOnFailure(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	r := newMemRecorder()
+	r.fail = true
+	if _, err := record(r, Click, id, "u1", now, nil); err == nil {
+		t.Fatal("record succeeded while counting failed")
+	}
+	if len(r.seen) != 0 {
+		t.Fatalf("seen = %v, want the mark undone", r.seen)
+	}
+	if len(r.days) != 0 {
+		t.Fatalf("daily = %v, want nothing recorded", r.days)
+	}
+
+	// 重试时仍计为当天首次
+	r.fail = false
+	unique, err := record(r, Click, id, "u1", now, nil)
+	if err != nil || !unique || r.counts[Click] != 1 {
+		t.Fatalf("retry = %v, %v, count %d, want first of the day", unique, err, r.counts[Click])
+	}
+}
+
+func TestRecordOptions(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	r := newMemRecorder()
+	if _, err := record(r, Click, id, "u1", time.Now(), &RecordOptions{SkipDaily: true}); err != nil {
+		t.Fatal(err)
+	}
+	if r.counts[Click] != 1 || len(r.days) != 0 {
+		t.Fatalf("counts = %v, daily = %v, want count without daily", r.counts, r.days)
+	}
+}
+
+func TestRecordDayByLocation(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	shanghai := time.FixedZone("CST", 8*3600)
+	// UTC 2024-01-01 20:00 为上海 2024-01-02 04:00
+	at := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	r := newMemRecorder()
+	if _, err := record(r, Click, id, "u1", at.In(shanghai), nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.days["click:2024-01-02"] != 1 {
+		t.Fatalf("daily = %v, want 2024-01-02", r.days)
+	}
+}
+
+func TestRecordInvalid(t *testing.T) {
+	r := newMemRecorder()
+	if _, err := record(r, Event("share"), primitive.NewObjectID().Hex(), "u1", time.Now(), nil); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("unknown event: %v, want %v", err, ErrUnknownEvent)
+	}
+	if _, err := record(r, Click, "bad", "u1", time.Now(), nil); err == nil {
+		t.Error("invalid notification id accepted")
+	}
+	if len(r.seen) != 0 || len(r.counts) != 0 {
+		t.Error("invalid input recorded")
+	}
+}
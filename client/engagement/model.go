@@ -0,0 +1,91 @@
+package engagement
+
+import (
+	"time"
+
+	"github.com/open4go/model"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "client_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_data"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "engagement"
+	// seenSuffix 去重记录表后缀
+	seenSuffix = "_seen"
+	// dayLayout 日期格式
+	dayLayout = "2006-01-02"
+)
+
+// Event 通知的互动事件
+type Event string
+
+const (
+	// Impression 曝光
+	Impression Event = "impression"
+	// Click 点击
+	Click Event = "click"
+)
+
+// Model 通知每日互动统计
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// ID 统计键：通知:日期
+	ID string `json:"id" bson:"_id"`
+	// NotificationID 通知
+	NotificationID string `json:"notification_id" bson:"notification_id"`
+	// Day 日期，格式: YYYY-MM-DD
+	Day string `json:"day" bson:"day"`
+	// Impressions 曝光次数
+	Impressions int64 `json:"impressions" bson:"impressions"`
+	// Clicks 点击次数
+	Clicks int64 `json:"clicks" bson:"clicks"`
+	// UniqueImpressions 曝光用户数（每个用户每天计一次）
+	UniqueImpressions int64 `json:"unique_impressions" bson:"unique_impressions"`
+	// UniqueClicks 点击用户数（每个用户每天计一次）
+	UniqueClicks int64 `json:"unique_clicks" bson:"unique_clicks"`
+}
+
+// Report 通知的点击率报表
+type Report struct {
+	// NotificationID 通知
+	NotificationID string `json:"notification_id"`
+	// Impressions 曝光数（按用户每天去重）
+	Impressions int64 `json:"impressions"`
+	// Clicks 点击数（按用户每天去重）
+	Clicks int64 `json:"clicks"`
+	// CTR 点击率
+	CTR float64 `json:"ctr"`
+	// Days 每日明细
+	Days []*Model `json:"days"`
+}
+
+// seen 用户当天已记录的事件，次日由 TTL 索引自动清理
+type seen struct {
+	// ID 去重键：事件:通知:用户:日期
+	ID string `bson:"_id"`
+	// ExpireAt 过期时间（TTL）
+	ExpireAt time.Time `bson:"expire_at"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
+
+// SeenCollectionName 返回去重记录表名称
+func (m *Model) SeenCollectionName() string {
+	return collectionNamePrefix + modelName + seenSuffix
+}
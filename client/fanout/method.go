@@ -0,0 +1,138 @@
+package fanout
+
+import (
+	"errors"
+	"time"
+
+	"github.com/open4go/model"
+	"github.com/r2day/m3s/client/notification"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrFinished 任务已结束
+	ErrFinished = errors.New("fanout job already finished")
+	// ErrNotResumable 只有失败或已取消的任务可以继续执行
+	ErrNotResumable = errors.New("fanout job is not failed or cancelled")
+)
+
+// Start 创建分发任务，由 Runner 执行
+// 通知改为仅投递到收件箱，只有分发到的用户可见；通知不属于该门店时返回 mongo.ErrNoDocuments
+// total 为预计用户总数，用于计算进度，未知时传 0
+func (m *Model) Start(storeID, notificationID string, total int64, batchSize int) (string, error) {
+	objID, err := primitive.ObjectIDFromHex(notificationID)
+	if err != nil {
+		return "", err
+	}
+	if _, err = primitive.ObjectIDFromHex(storeID); err != nil {
+		return "", err
+	}
+	n := &notification.Model{}
+	n.Init(m.Context.Context, m.Context.Handler, n.CollectionName())
+	if err = n.SetDelivery(storeID, notificationID, notification.DeliveryInbox); err != nil {
+		return "", err
+	}
+	m.StoreID = storeID
+	m.NotificationID = objID
+	m.Operator = model.GetValueFromCtx(m.Context.Context, model.OperatorKey)
+	m.State = Pending
+	m.Total = total
+	m.BatchSize = batchSize
+	m.CreatedTime = time.Now().Unix()
+	return m.Create(m)
+}
+
+// Get 获取任务及进度
+func (m *Model) Get(id string) (*Model, error) {
+	result := &Model{}
+	if err := m.GetOne(result, id); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Cancel 取消任务，执行中的任务在当前批次完成后停止
+func (m *Model) Cancel(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	result, err := coll.UpdateOne(m.Context.Context,
+		bson.D{
+			{Key: "_id", Value: objID},
+			{Key: "state", Value: bson.D{{Key: "$in", Value: bson.A{Pending, Running}}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "state", Value: Cancelled},
+			{Key: "finished_time", Value: time.Now().Unix()},
+		}}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrFinished
+	}
+	return nil
+}
+
+// Resume 继续执行失败或已取消的任务，由 Runner 从记录的位置继续
+func (m *Model) Resume(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	result, err := coll.UpdateOne(m.Context.Context,
+		bson.D{
+			{Key: "_id", Value: objID},
+			{Key: "state", Value: bson.D{{Key: "$in", Value: bson.A{Failed, Cancelled}}}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "state", Value: Pending}}},
+			{Key: "$unset", Value: bson.D{
+				{Key: "attempts", Value: ""},
+				{Key: "error", Value: ""},
+				{Key: "finished_time", Value: ""},
+			}},
+		})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotResumable
+	}
+	return nil
+}
+
+// GetByNotificationID 获取通知的分发任务，按创建时间倒序
+func (m *Model) GetByNotificationID(id string) ([]*Model, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	results := make([]*Model, 0)
+	cursor, err := coll.Find(m.Context.Context, bson.D{{Key: "notification_id", Value: objID}},
+		options.Find().SetSort(bson.D{{Key: "created_time", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CreateIndexes 创建任务领取与查询使用的索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateMany(m.Context.Context, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "lease_expire_time", Value: 1}}},
+		{Keys: bson.D{{Key: "notification_id", Value: 1}}},
+	})
+	return err
+}
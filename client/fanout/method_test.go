@@ -0,0 +1,26 @@
+package fanout
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestStartInvalidIDs(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	tests := []struct {
+		name           string
+		storeID        string
+		notificationID string
+	}{
+		{"invalid notification", id, "n1"},
+		{"invalid store", "s1", id},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&Model{}).Start(tt.storeID, tt.notificationID, 0, 0); err == nil {
+				t.Error("Start() error = nil, want an invalid id error")
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package fanout
+
+import (
+	"github.com/open4go/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "client_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_job_flow"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "fanout"
+)
+
+// State 任务状态
+type State string
+
+const (
+	// Pending 等待执行
+	Pending State = "pending"
+	// Running 执行中
+	Running State = "running"
+	// Completed 已完成
+	Completed State = "completed"
+	// Cancelled 已取消
+	Cancelled State = "cancelled"
+	// Failed 执行失败
+	Failed State = "failed"
+)
+
+// Final 是否为最终状态
+func (s State) Final() bool {
+	return s == Completed || s == Cancelled || s == Failed
+}
+
+// Model 通知分发任务
+// 将通知按批次写入用户收件箱，记录已处理到的用户以便中断后继续
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// StoreID 门店
+	StoreID string `json:"store_id" bson:"store_id"`
+	// NotificationID 通知
+	NotificationID primitive.ObjectID `json:"notification_id" bson:"notification_id"`
+	// Operator 创建人
+	Operator string `json:"operator" bson:"operator,omitempty"`
+	// State 状态
+	State State `json:"state" bson:"state"`
+	// Total 预计用户总数（0 表示未知）
+	Total int64 `json:"total" bson:"total,omitempty"`
+	// Processed 已处理的用户数
+	Processed int64 `json:"processed" bson:"processed"`
+	// Batches 已完成的批次数
+	Batches int64 `json:"batches" bson:"batches"`
+	// Cursor 最后处理的用户，继续执行时从其之后开始
+	Cursor string `json:"cursor" bson:"cursor,omitempty"`
+	// BatchSize 每批处理的用户数
+	BatchSize int `json:"batch_size" bson:"batch_size,omitempty"`
+	// Attempts 连续失败次数，批次成功后清零
+	Attempts int `json:"attempts" bson:"attempts,omitempty"`
+	// Error 最近一次失败原因
+	Error string `json:"error" bson:"error,omitempty"`
+	// LeaseOwner 执行实例
+	LeaseOwner string `json:"lease_owner" bson:"lease_owner,omitempty"`
+	// LeaseExpireTime 租约过期时间，过期后其他实例可接手继续执行
+	LeaseExpireTime int64 `json:"lease_expire_time" bson:"lease_expire_time,omitempty"`
+	// CreatedTime 创建时间
+	CreatedTime int64 `json:"created_time" bson:"created_time"`
+	// StartedTime 开始时间
+	StartedTime int64 `json:"started_time" bson:"started_time,omitempty"`
+	// FinishedTime 结束时间
+	FinishedTime int64 `json:"finished_time" bson:"finished_time,omitempty"`
+}
+
+// Progress 完成比例（0-1），总数未知时返回 -1
+func (m *Model) Progress() float64 {
+	if m.State == Completed {
+		return 1
+	}
+	if m.Total <= 0 {
+		return -1
+	}
+	if m.Processed >= m.Total {
+		return 1
+	}
+	return float64(m.Processed) / float64(m.Total)
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
@@ -0,0 +1,314 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/open4go/log"
+	"github.com/r2day/m3s/client/inbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultLease 默认任务租约时长
+	defaultLease = time.Minute
+	// defaultBatchSize 默认每批处理的用户数
+	defaultBatchSize = 1000
+	// defaultPollInterval 没有任务时的轮询间隔
+	defaultPollInterval = 5 * time.Second
+	// defaultMaxAttempts 默认连续失败多少次后标记任务失败
+	defaultMaxAttempts = 5
+	// defaultRetryBackoff 默认首次重试的等待时间，之后每次翻倍
+	defaultRetryBackoff = 30 * time.Second
+	// maxRetryBackoff 重试等待时间上限
+	maxRetryBackoff = 10 * time.Minute
+)
+
+// ErrLeaseLost 任务已被取消或由其他实例接手
+var ErrLeaseLost = errors.New("fanout job cancelled or lease lost")
+
+// Source 分发的目标用户
+type Source interface {
+	// Users 按用户编号升序返回 after 之后的最多 limit 个用户，没有更多用户时返回空
+	Users(ctx context.Context, job *Model, after string, limit int) ([]string, error)
+}
+
+// SliceSource 固定的用户列表
+type SliceSource []string
+
+// Users 按用户编号升序返回 after 之后的用户
+func (s SliceSource) Users(ctx context.Context, job *Model, after string, limit int) ([]string, error) {
+	users := append([]string(nil), s...)
+	sort.Strings(users)
+	start := sort.SearchStrings(users, after)
+	if start < len(users) && users[start] == after {
+		start++
+	}
+	end := start + limit
+	if end > len(users) {
+		end = len(users)
+	}
+	return users[start:end], nil
+}
+
+// Runner 执行分发任务
+// 通过租约领取任务，每批写入收件箱后记录进度；实例崩溃后租约过期，由其他实例从记录的位置继续
+// 收件箱写入是幂等的，重复执行同一批次不会产生重复数据
+// 批次出错时任务保持执行中，租约按退避时间延后，到期后由任一实例从记录的位置重试；连续失败 MaxAttempts 次后标记失败
+type Runner struct {
+	// DB 数据库
+	DB *mongo.Database
+	// Source 目标用户
+	Source Source
+	// Owner 实例标识，为空时使用主机名与进程号
+	Owner string
+	// Lease 租约时长，需大于单批处理的耗时
+	Lease time.Duration
+	// BatchSize 任务未设置时每批处理的用户数
+	BatchSize int
+	// PollInterval 没有任务时的轮询间隔
+	PollInterval time.Duration
+	// MaxAttempts 连续失败多少次后标记任务失败
+	MaxAttempts int
+	// RetryBackoff 首次重试的等待时间，之后每次翻倍
+	RetryBackoff time.Duration
+}
+
+// NewRunner 创建任务执行
+func NewRunner(db *mongo.Database, source Source) *Runner {
+	host, _ := os.Hostname()
+	return &Runner{
+		DB:           db,
+		Source:       source,
+		Owner:        fmt.Sprintf("%s-%d", host, os.Getpid()),
+		Lease:        defaultLease,
+		BatchSize:    defaultBatchSize,
+		PollInterval: defaultPollInterval,
+		MaxAttempts:  defaultMaxAttempts,
+		RetryBackoff: defaultRetryBackoff,
+	}
+}
+
+// Run 持续领取并执行任务直到 ctx 结束
+func (r *Runner) Run(ctx context.Context) error {
+	for {
+		job, err := r.claim(ctx)
+		if err == nil {
+			if err = r.execute(ctx, job); err != nil && !errors.Is(err, ErrLeaseLost) {
+				log.Log(ctx).WithField("job", job.ID.Hex()).Error(err)
+			}
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Log(ctx).WithField("owner", r.Owner).Error(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RunOnce 领取并执行一个任务，没有任务时返回 mongo.ErrNoDocuments
+func (r *Runner) RunOnce(ctx context.Context) (*Model, error) {
+	job, err := r.claim(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return job, r.execute(ctx, job)
+}
+
+// collection 任务表
+func (r *Runner) collection() *mongo.Collection {
+	return r.DB.Collection((&Model{}).CollectionName())
+}
+
+// claim 领取等待执行或租约已过期的任务
+func (r *Runner) claim(ctx context.Context) (*Model, error) {
+	now := time.Now()
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "state", Value: Pending}},
+		bson.D{
+			{Key: "state", Value: Running},
+			{Key: "lease_expire_time", Value: bson.D{{Key: "$lte", Value: now.Unix()}}},
+		},
+	}}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "state", Value: Running},
+			{Key: "lease_owner", Value: r.Owner},
+			{Key: "lease_expire_time", Value: now.Add(r.Lease).Unix()},
+		}},
+		{Key: "$min", Value: bson.D{{Key: "started_time", Value: now.Unix()}}},
+	}
+	job := &Model{}
+	err := r.collection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "created_time", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(job)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// execute 从任务记录的位置继续分批写入收件箱
+func (r *Runner) execute(ctx context.Context, job *Model) error {
+	batchSize := job.BatchSize
+	if batchSize <= 0 {
+		batchSize = r.BatchSize
+	}
+	box := &inbox.Model{}
+	box.Init(ctx, r.DB, box.CollectionName())
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		users, err := r.Source.Users(ctx, job, job.Cursor, batchSize)
+		if err != nil {
+			return r.retry(ctx, job, err)
+		}
+		if len(users) == 0 {
+			return r.finish(ctx, job)
+		}
+		if err = box.Deliver(job.StoreID, job.NotificationID, users); err != nil {
+			return r.retry(ctx, job, err)
+		}
+		if err = r.advance(ctx, job, users[len(users)-1], int64(len(users))); err != nil {
+			return err
+		}
+		if len(users) < batchSize {
+			return r.finish(ctx, job)
+		}
+	}
+}
+
+// advance 记录批次进度并续租，同时清除连续失败次数
+// 任务已被取消或租约被其他实例接手时返回 ErrLeaseLost
+func (r *Runner) advance(ctx context.Context, job *Model, cursor string, n int64) error {
+	result, err := r.collection().UpdateOne(ctx, r.owned(job), bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "cursor", Value: cursor},
+			{Key: "attempts", Value: 0},
+			{Key: "lease_expire_time", Value: time.Now().Add(r.Lease).Unix()},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "processed", Value: n}, {Key: "batches", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLeaseLost
+	}
+	job.Cursor = cursor
+	job.Processed += n
+	job.Batches++
+	job.Attempts = 0
+	return nil
+}
+
+// retry 记录失败并延后租约，到期后从记录的位置重试；连续失败达到上限时标记任务失败
+// 返回原始错误
+func (r *Runner) retry(ctx context.Context, job *Model, cause error) error {
+	if ctx.Err() != nil {
+		// 实例停止，保留租约由其过期后接手
+		return cause
+	}
+	attempts := job.Attempts + 1
+	if attempts >= r.maxAttempts() {
+		return r.fail(ctx, job, cause)
+	}
+	result, err := r.collection().UpdateOne(ctx, r.owned(job), bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "attempts", Value: attempts},
+			{Key: "error", Value: cause.Error()},
+			{Key: "lease_expire_time", Value: time.Now().Add(r.backoff(attempts)).Unix()},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLeaseLost
+	}
+	job.Attempts = attempts
+	job.Error = cause.Error()
+	return cause
+}
+
+// maxAttempts 连续失败的上限
+func (r *Runner) maxAttempts() int {
+	if r.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return r.MaxAttempts
+}
+
+// backoff 第 attempts 次失败后的等待时间
+func (r *Runner) backoff(attempts int) time.Duration {
+	d := r.RetryBackoff
+	if d <= 0 {
+		d = defaultRetryBackoff
+	}
+	for i := 1; i < attempts && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d
+}
+
+// finish 标记任务完成
+func (r *Runner) finish(ctx context.Context, job *Model) error {
+	return r.end(ctx, job, Completed, "")
+}
+
+// fail 标记任务失败，并返回原始错误
+func (r *Runner) fail(ctx context.Context, job *Model, cause error) error {
+	if err := r.end(ctx, job, Failed, cause.Error()); err != nil {
+		return err
+	}
+	return cause
+}
+
+// end 结束任务
+func (r *Runner) end(ctx context.Context, job *Model, state State, reason string) error {
+	set := bson.D{
+		{Key: "state", Value: state},
+		{Key: "finished_time", Value: time.Now().Unix()},
+	}
+	if reason != "" {
+		set = append(set, bson.E{Key: "error", Value: reason})
+	}
+	result, err := r.collection().UpdateOne(ctx, r.owned(job), bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$unset", Value: bson.D{{Key: "lease_owner", Value: ""}, {Key: "lease_expire_time", Value: ""}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLeaseLost
+	}
+	job.State = state
+	return nil
+}
+
+// owned 当前实例持有租约且仍在执行的任务
+func (r *Runner) owned(job *Model) bson.D {
+	return bson.D{
+		{Key: "_id", Value: job.ID},
+		{Key: "state", Value: Running},
+		{Key: "lease_owner", Value: r.Owner},
+	}
+}
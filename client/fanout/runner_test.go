@@ -0,0 +1,116 @@
+package fanout
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSliceSourceUsers(t *testing.T) {
+	source := SliceSource{"u3", "u1", "u5", "u2", "u4"}
+	tests := []struct {
+		after string
+		limit int
+		want  []string
+	}{
+		{"", 2, []string{"u1", "u2"}},
+		{"u2", 2, []string{"u3", "u4"}},
+		{"u4", 2, []string{"u5"}},
+		{"u5", 2, []string{}},
+		{"u25", 10, []string{"u3", "u4", "u5"}},
+		{"", 10, []string{"u1", "u2", "u3", "u4", "u5"}},
+		{"a", 1, []string{"u1"}},
+		{"z", 1, []string{}},
+	}
+	for _, tt := range tests {
+		got, err := source.Users(context.Background(), &Model{}, tt.after, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Users(%q, %d) = %v, want %v", tt.after, tt.limit, got, tt.want)
+		}
+	}
+	if !reflect.DeepEqual([]string(source), []string{"u3", "u1", "u5", "u2", "u4"}) {
+		t.Errorf("source was modified: %v", source)
+	}
+}
+
+func TestSliceSourceCoversAllUsers(t *testing.T) {
+	source := SliceSource{"c", "a", "e", "b", "d", "f", "g"}
+	seen := make([]string, 0)
+	cursor := ""
+	for {
+		batch, _ := source.Users(context.Background(), &Model{}, cursor, 3)
+		if len(batch) == 0 {
+			break
+		}
+		seen = append(seen, batch...)
+		cursor = batch[len(batch)-1]
+	}
+	if want := []string{"a", "b", "c", "d", "e", "f", "g"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("batches = %v, want %v", seen, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Model
+		want float64
+	}{
+		{"unknown total", Model{State: Running, Processed: 10}, -1},
+		{"completed unknown total", Model{State: Completed, Processed: 10}, 1},
+		{"half", Model{State: Running, Total: 200, Processed: 100}, 0.5},
+		{"not started", Model{State: Pending, Total: 200}, 0},
+		{"over estimate", Model{State: Running, Total: 100, Processed: 150}, 1},
+		{"failed", Model{State: Failed, Total: 100, Processed: 25}, 0.25},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Progress(); got != tt.want {
+			t.Errorf("%s: Progress = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStateFinal(t *testing.T) {
+	tests := map[State]bool{
+		Pending:   false,
+		Running:   false,
+		Completed: true,
+		Cancelled: true,
+		Failed:    true,
+	}
+	for s, want := range tests {
+		if got := s.Final(); got != want {
+			t.Errorf("%s.Final() = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestRunnerBackoff(t *testing.T) {
+	r := &Runner{RetryBackoff: time.Minute}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, maxRetryBackoff},
+		{50, maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		if got := r.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+	if got := (&Runner{}).backoff(1); got != defaultRetryBackoff {
+		t.Errorf("default backoff = %v, want %v", got, defaultRetryBackoff)
+	}
+	if got := (&Runner{}).maxAttempts(); got != defaultMaxAttempts {
+		t.Errorf("default max attempts = %d, want %d", got, defaultMaxAttempts)
+	}
+}
@@ -2,6 +2,11 @@ package notification
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/r2day/m3s/client/i18n"
+	"github.com/r2day/m3s/client/page"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -29,3 +34,179 @@ func (m *Model) GetByStoreID(id string) ([]*Model, error) {
 	}
 	return results, nil
 }
+
+// ValidateText 校验多语言文本包含默认语言
+func (m *Model) ValidateText() error {
+	texts := map[string]i18n.Text{
+		"recommend_title":      m.RecommendTitle,
+		"home_config.greeting": m.HomeConfig.Greeting,
+	}
+	for name, entrances := range map[string][]*Entrance{
+		"home_config.entrances":  m.HomeConfig.Entrances,
+		"home_config.navigators": m.HomeConfig.Navigators,
+		"login.bottom":           m.Login.Bottom,
+	} {
+		for i, e := range entrances {
+			if e != nil {
+				texts[fmt.Sprintf("%s.%d.title", name, i)] = e.Title
+			}
+		}
+	}
+	for name, text := range texts {
+		if err := text.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Localize 返回按请求语言解析文本后的副本
+func (m *Model) Localize(locale string) *Model {
+	result := *m
+	result.RecommendTitle = m.RecommendTitle.Only(locale)
+	result.HomeConfig.Greeting = m.HomeConfig.Greeting.Only(locale)
+	result.HomeConfig.Entrances = localizeEntrances(m.HomeConfig.Entrances, locale)
+	result.HomeConfig.Navigators = localizeEntrances(m.HomeConfig.Navigators, locale)
+	result.Login.Bottom = localizeEntrances(m.Login.Bottom, locale)
+	return &result
+}
+
+// localizeEntrances 复制入口列表并解析标题
+func localizeEntrances(entrances []*Entrance, locale string) []*Entrance {
+	if entrances == nil {
+		return nil
+	}
+	results := make([]*Entrance, 0, len(entrances))
+	for _, e := range entrances {
+		if e == nil {
+			results = append(results, nil)
+			continue
+		}
+		item := *e
+		item.Title = e.Title.Only(locale)
+		results = append(results, &item)
+	}
+	return results
+}
+
+// Links 配置中所有图片展示区域的跳转链接
+func (m *Model) Links() []*Link {
+	links := make([]*Link, 0)
+	add := func(field string, items []*ImageDisplayConfig) {
+		for i, item := range items {
+			if item != nil && item.Url != "" {
+				links = append(links, &Link{
+					Field:       fmt.Sprintf("%s.%d.url", field, i),
+					PackageType: item.PackageType,
+					Url:         item.Url,
+				})
+			}
+		}
+	}
+	add("recommend_show_list", m.RecommendShowList)
+	add("home_config.background", m.HomeConfig.Background)
+	add("home_config.recommend_show_list", m.HomeConfig.RecommendShowList)
+	add("menu_config.carousel", m.MenuConfig.Carousel)
+	service := make([]*ImageDisplayConfig, 0, len(m.ProfileConfig.Service))
+	for i := range m.ProfileConfig.Service {
+		service = append(service, &m.ProfileConfig.Service[i])
+	}
+	add("profile_config.service", service)
+	return links
+}
+
+// AppType 配置的小程序类型，未设置时为微信小程序
+func (m *Model) AppType() AppType {
+	if m.Type == nil {
+		return WxApp
+	}
+	return *m.Type
+}
+
+// CheckLinks 校验配置中的跳转链接，返回所有无效链接
+func (m *Model) CheckLinks(r *page.Registry) []*Link {
+	broken := make([]*Link, 0)
+	for _, link := range m.Links() {
+		if err := r.Check([]AppType{m.AppType()}, link.PackageType, link.Url); err != nil {
+			link.Error = err.Error()
+			broken = append(broken, link)
+		}
+	}
+	return broken
+}
+
+// ValidateLinks 校验配置中的跳转链接均为已登记的页面
+func (m *Model) ValidateLinks() error {
+	return m.checkLinks(m)
+}
+
+// Validate 校验多语言文本与跳转链接
+func (m *Model) Validate() error {
+	return m.validate(m)
+}
+
+// Create 校验后保存主页配置
+func (m *Model) Create(d interface{}) (string, error) {
+	if h, ok := d.(*Model); ok {
+		if err := m.validate(h); err != nil {
+			return "", err
+		}
+	}
+	return m.Model.Create(d)
+}
+
+// Update 校验后更新主页配置
+// 未设置小程序类型时按已保存的小程序类型校验跳转链接
+func (m *Model) Update(d interface{}, id string) error {
+	if h, ok := d.(*Model); ok {
+		merged := *h
+		if merged.Type == nil && len(merged.Links()) > 0 {
+			saved := &Model{}
+			err := m.Model.GetOne(saved, id)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+			merged.Type = saved.Type
+		}
+		if err := m.validate(&merged); err != nil {
+			return err
+		}
+	}
+	return m.Model.Update(d, id)
+}
+
+func (m *Model) validate(h *Model) error {
+	if err := h.ValidateText(); err != nil {
+		return err
+	}
+	return m.checkLinks(h)
+}
+
+// checkLinks 使用 m 的数据库连接校验 h 的跳转链接，只加载链接涉及的页面
+func (m *Model) checkLinks(h *Model) error {
+	links := h.Links()
+	if len(links) == 0 {
+		return nil
+	}
+	packageTypes := make([]string, 0, len(links))
+	urls := make([]string, 0, len(links))
+	for _, link := range links {
+		packageTypes = append(packageTypes, link.PackageType)
+		urls = append(urls, link.Url)
+	}
+	p := &page.Model{}
+	p.Init(m.Context.Context, m.Context.Handler, p.CollectionName())
+	r, err := p.LoadFor(packageTypes, urls)
+	if err != nil {
+		return err
+	}
+	broken := h.CheckLinks(r)
+	if len(broken) == 0 {
+		return nil
+	}
+	reasons := make([]string, 0, len(broken))
+	for _, link := range broken {
+		reasons = append(reasons, link.Field+": "+link.Error)
+	}
+	return fmt.Errorf("%w: %s", page.ErrBrokenLink, strings.Join(reasons, "; "))
+}
@@ -2,6 +2,8 @@ package notification
 
 import (
 	"github.com/open4go/model"
+	"github.com/r2day/m3s/client/app"
+	"github.com/r2day/m3s/client/i18n"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -18,15 +20,15 @@ const (
 )
 
 // AppType 小程序类型
-type AppType int
+type AppType = app.AppType
 
 const (
 	// WxApp 微信小程序
-	WxApp AppType = iota
+	WxApp = app.WxApp
 	// AlipayApp 支付宝
-	AlipayApp
+	AlipayApp = app.AlipayApp
 	// DouYin 抖音小程序
-	DouYin
+	DouYin = app.DouYin
 )
 
 // Model 小程序主页的配置
@@ -38,14 +40,14 @@ type Model struct {
 	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	// Name 名称
 	Name string `json:"name" bson:"name,omitempty"`
-	// 小程序类型
-	Type AppType `json:"type" bson:"type,omitempty"`
+	// 小程序类型，为空时为微信小程序；更新时为空表示不修改
+	Type *AppType `json:"type" bson:"type,omitempty"`
 	// logo
 	Logo string `json:"logo" bson:"logo,omitempty"`
 	// Background 背景颜色
 	Background string `json:"background" bson:"background,omitempty"`
-	// 推荐区域的标题（例如：火热的，热销的，招牌的）
-	RecommendTitle string `json:"recommend_title" bson:"recommend_title,omitempty"`
+	// 推荐区域的标题（例如：火热的，热销的，招牌的），多语言
+	RecommendTitle i18n.Text `json:"recommend_title" bson:"recommend_title,omitempty"`
 	// 推荐列表展示
 	RecommendShowList []*ImageDisplayConfig `json:"recommend_show_list" bson:"recommend_show_list,omitempty"`
 	// Enable 启用与否
@@ -126,8 +128,8 @@ type BaseIcon struct {
 type Entrance struct {
 	// 操作的函数
 	Tap string `json:"tap" bson:"tap,omitempty"`
-	// 主标题
-	Title string `json:"title" bson:"title,omitempty"`
+	// 主标题（多语言）
+	Title i18n.Text `json:"title" bson:"title,omitempty"`
 	// 子标题
 	SubTitle string `json:"sub_title" bson:"sub_title,omitempty"`
 	// 英文名称
@@ -160,12 +162,24 @@ type ImageDisplayConfig struct {
 	Type int `json:"type" bson:"type,omitempty"`
 }
 
+// Link 配置中的跳转链接
+type Link struct {
+	// Field 所在字段，例如：home_config.background.0.url
+	Field string `json:"field"`
+	// PackageType 包类型
+	PackageType string `json:"package_type"`
+	// Url 链接
+	Url string `json:"url"`
+	// Error 校验失败原因
+	Error string `json:"error,omitempty"`
+}
+
 // HomePageConfig 主页页面配置管理
 type HomePageConfig struct {
 	// 轮播背景图片展示
 	Background []*ImageDisplayConfig `json:"background" bson:"background,omitempty"`
-	// Greeting 问候语，例如：Hello, Frank
-	Greeting string `json:"greeting" bson:"greeting,omitempty"`
+	// Greeting 问候语，例如：Hello, Frank（多语言）
+	Greeting i18n.Text `json:"greeting" bson:"greeting,omitempty"`
 	// Remark 备注信息
 	Remark string `json:"remark" bson:"remark,omitempty"`
 	// ShowMerchantName 是否展示门店名称
@@ -0,0 +1,239 @@
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// DefaultLocale 默认语言，所有多语言文本都必须包含该语言
+const DefaultLocale = "zh-CN"
+
+// Locales 语言回退设置
+// 查找顺序：请求语言、Fallbacks 中配置的语言、请求语言的上级（zh-Hant-TW -> zh-Hant -> zh）、DefaultLocale
+type Locales struct {
+	// Fallbacks 额外的回退语言，例如：{"zh-HK": {"zh-TW"}}
+	Fallbacks map[string][]string
+}
+
+// ErrNoDefaultLocale 缺少默认语言
+var ErrNoDefaultLocale = errors.New("default locale text is required")
+
+// Text 多语言文本：语言 -> 文本
+// 兼容旧数据，BSON/JSON 中的字符串会被视为默认语言的文本
+// 输出 JSON 时为全部语言的文本；按请求语言返回给旧接口时先调用 Only，输出为字符串
+type Text map[string]string
+
+// localizedKey Only 结果中唯一的键，表示文本已按请求语言解析
+const localizedKey = ""
+
+// NewText 创建默认语言的文本
+func NewText(s string) Text {
+	return Text{DefaultLocale: s}
+}
+
+// Normalize 规整语言标签，例如：zh_cn -> zh-CN
+func Normalize(locale string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 2:
+			parts[i] = strings.ToUpper(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// Chain 语言的回退链（不含额外的回退语言）
+func Chain(locale string) []string {
+	return (*Locales)(nil).Chain(locale)
+}
+
+// Chain 语言的回退链
+func (l *Locales) Chain(locale string) []string {
+	chain := make([]string, 0, 4)
+	seen := make(map[string]bool)
+	add := func(l string) {
+		l = Normalize(l)
+		if l != "" && !seen[l] {
+			seen[l] = true
+			chain = append(chain, l)
+		}
+	}
+	locale = Normalize(locale)
+	add(locale)
+	if l != nil {
+		for _, f := range l.Fallbacks[locale] {
+			add(f)
+		}
+	}
+	for i := strings.LastIndex(locale, "-"); i > 0; i = strings.LastIndex(locale, "-") {
+		locale = locale[:i]
+		add(locale)
+	}
+	add(DefaultLocale)
+	return chain
+}
+
+// Get 按回退链获取文本，都没有时按语言标签顺序返回第一个非空文本
+func (l *Locales) Get(t Text, locale string) string {
+	if len(t) == 0 {
+		return ""
+	}
+	for _, c := range l.Chain(locale) {
+		if s, ok := t.lookup(c); ok {
+			return s
+		}
+	}
+	for _, k := range t.keys() {
+		if t[k] != "" {
+			return t[k]
+		}
+	}
+	return ""
+}
+
+// Only 只保留请求语言解析出的文本，用于按请求语言返回给客户端，输出 JSON 时为字符串
+func (l *Locales) Only(t Text, locale string) Text {
+	return Text{localizedKey: l.Get(t, locale)}
+}
+
+// Get 按回退链获取文本（不含额外的回退语言）
+func (t Text) Get(locale string) string {
+	return (*Locales)(nil).Get(t, locale)
+}
+
+// keys 排序后的语言标签，保证回退结果稳定
+func (t Text) keys() []string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lookup 查找语言的文本，忽略标签的大小写与分隔符差异
+func (t Text) lookup(locale string) (string, bool) {
+	if s, ok := t[locale]; ok && s != "" {
+		return s, true
+	}
+	for _, k := range t.keys() {
+		if t[k] != "" && Normalize(k) == locale {
+			return t[k], true
+		}
+	}
+	return "", false
+}
+
+// String 默认语言的文本
+func (t Text) String() string {
+	return t.Get(DefaultLocale)
+}
+
+// Only 只保留请求语言解析出的文本（不含额外的回退语言）
+func (t Text) Only(locale string) Text {
+	return (*Locales)(nil).Only(t, locale)
+}
+
+// Validate 校验非空文本包含默认语言
+func (t Text) Validate() error {
+	if len(t) == 0 {
+		return nil
+	}
+	if _, ok := t.lookup(Normalize(DefaultLocale)); !ok {
+		return fmt.Errorf("%w: %s", ErrNoDefaultLocale, DefaultLocale)
+	}
+	return nil
+}
+
+// UnmarshalBSONValue 兼容旧数据中的字符串
+func (t *Text) UnmarshalBSONValue(typ bsontype.Type, data []byte) error {
+	switch typ {
+	case bsontype.Null, bsontype.Undefined:
+		*t = nil
+		return nil
+	case bsontype.String:
+		var s string
+		if err := bson.UnmarshalValue(typ, data, &s); err != nil {
+			return err
+		}
+		*t = legacy(s)
+		return nil
+	}
+	values := make(map[string]string)
+	if err := bson.UnmarshalValue(typ, data, &values); err != nil {
+		return err
+	}
+	*t = values
+	return nil
+}
+
+// MarshalJSON 输出全部语言的文本
+// 经 Only 处理后输出请求语言的字符串，与旧接口保持一致
+func (t Text) MarshalJSON() ([]byte, error) {
+	if s, ok := t[localizedKey]; ok && len(t) == 1 {
+		return json.Marshal(s)
+	}
+	return json.Marshal(map[string]string(t))
+}
+
+// UnmarshalJSON 兼容旧接口中的字符串
+func (t *Text) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = legacy(s)
+		return nil
+	}
+	values := make(map[string]string)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*t = values
+	return nil
+}
+
+// legacy 字符串转换为默认语言的文本
+func legacy(s string) Text {
+	if s == "" {
+		return nil
+	}
+	return NewText(s)
+}
+
+// ParseAcceptLanguage 获取 Accept-Language 中优先级最高的语言，为空时返回默认语言
+func ParseAcceptLanguage(header string) string {
+	best, bestQ := "", -1.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if _, err := fmt.Sscanf(f[2:], "%g", &q); err != nil {
+					q = 0
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	if best == "" {
+		return DefaultLocale
+	}
+	return Normalize(best)
+}
@@ -0,0 +1,184 @@
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"zh_cn":      "zh-CN",
+		"EN-us":      "en-US",
+		"zh-hant-tw": "zh-Hant-TW",
+		" ja ":       "ja",
+		"":           "",
+	}
+	for in, want := range tests {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	locales := &Locales{Fallbacks: map[string][]string{"zh-HK": {"zh-TW"}}}
+	tests := []struct {
+		l      *Locales
+		locale string
+		want   []string
+	}{
+		{nil, "en-US", []string{"en-US", "en", "zh-CN"}},
+		{nil, "zh_hant_tw", []string{"zh-Hant-TW", "zh-Hant", "zh", "zh-CN"}},
+		{nil, "zh-CN", []string{"zh-CN", "zh"}},
+		{nil, "", []string{"zh-CN"}},
+		{locales, "zh-HK", []string{"zh-HK", "zh-TW", "zh", "zh-CN"}},
+		{locales, "en", []string{"en", "zh-CN"}},
+	}
+	for _, tt := range tests {
+		if got := tt.l.Chain(tt.locale); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Chain(%q) = %v, want %v", tt.locale, got, tt.want)
+		}
+	}
+	if got := Chain("en-US"); !reflect.DeepEqual(got, []string{"en-US", "en", "zh-CN"}) {
+		t.Errorf("package Chain = %v", got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	text := Text{"zh-CN": "你好", "en": "Hello", "zh_TW": "妳好", "fr": ""}
+	locales := &Locales{Fallbacks: map[string][]string{"zh-HK": {"zh-TW"}}}
+	tests := []struct {
+		l      *Locales
+		t      Text
+		locale string
+		want   string
+	}{
+		{nil, text, "en-GB", "Hello"},
+		{nil, text, "zh-tw", "妳好"},
+		{nil, text, "zh-HK", "你好"},
+		{locales, text, "zh-HK", "妳好"},
+		{nil, text, "fr", "你好"},
+		{nil, text, "ja", "你好"},
+		{nil, Text{"fr": "Bonjour", "de": "Hallo", "en": ""}, "ja", "Hallo"},
+		{nil, nil, "en", ""},
+		{nil, Text{"en": ""}, "en", ""},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			if got := tt.l.Get(tt.t, tt.locale); got != tt.want {
+				t.Fatalf("Get(%v, %q) = %q, want %q", tt.t, tt.locale, got, tt.want)
+			}
+		}
+	}
+	if got := text.Get("en"); got != "Hello" {
+		t.Errorf("Text.Get = %q", got)
+	}
+}
+
+func TestOnlyAndJSON(t *testing.T) {
+	text := Text{"zh-CN": "你好", "en": "Hello"}
+	if got := text.Only("en_us"); got.String() != "Hello" || got.Get("ja") != "Hello" {
+		t.Errorf("Only = %v", got)
+	}
+
+	tests := []struct {
+		t    Text
+		want string
+	}{
+		{text, `{"en":"Hello","zh-CN":"你好"}`},
+		{text.Only("en"), `"Hello"`},
+		{text.Only("ja"), `"你好"`},
+		{Text(nil).Only("en"), `""`},
+		{NewText("你好"), `{"zh-CN":"你好"}`},
+		{nil, `null`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.t)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("Marshal(%v) = %s, %v, want %s", tt.t, data, err, tt.want)
+		}
+	}
+
+	var decoded struct {
+		Old Text `json:"old"`
+		New Text `json:"new"`
+	}
+	if err := json.Unmarshal([]byte(`{"old":"你好","new":{"en":"Hello"}}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Old, Text{DefaultLocale: "你好"}) || !reflect.DeepEqual(decoded.New, Text{"en": "Hello"}) {
+		t.Errorf("Unmarshal = %+v", decoded)
+	}
+}
+
+func TestBSON(t *testing.T) {
+	type doc struct {
+		Title Text `bson:"title"`
+	}
+	tests := []struct {
+		in   bson.D
+		want Text
+	}{
+		{bson.D{{Key: "title", Value: "你好"}}, Text{DefaultLocale: "你好"}},
+		{bson.D{{Key: "title", Value: ""}}, nil},
+		{bson.D{{Key: "title", Value: nil}}, nil},
+		{bson.D{{Key: "title", Value: bson.D{{Key: "en", Value: "Hello"}}}}, Text{"en": "Hello"}},
+	}
+	for _, tt := range tests {
+		data, _ := bson.Marshal(tt.in)
+		var d doc
+		if err := bson.Unmarshal(data, &d); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(d.Title, tt.want) {
+			t.Errorf("Unmarshal(%v) = %v, want %v", tt.in, d.Title, tt.want)
+		}
+	}
+
+	// 存储时保留全部语言
+	data, _ := bson.Marshal(doc{Title: Text{"zh-CN": "你好", "en": "Hello"}})
+	var raw struct {
+		Title map[string]string `bson:"title"`
+	}
+	if err := bson.Unmarshal(data, &raw); err != nil || len(raw.Title) != 2 {
+		t.Errorf("stored title = %v, %v", raw.Title, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		t    Text
+		want error
+	}{
+		{nil, nil},
+		{Text{"zh_cn": "你好"}, nil},
+		{Text{"en": "Hello"}, ErrNoDefaultLocale},
+		{Text{"zh-CN": "", "en": "Hello"}, ErrNoDefaultLocale},
+	}
+	for _, tt := range tests {
+		if err := tt.t.Validate(); !errors.Is(err, tt.want) {
+			t.Errorf("Validate(%v) = %v, want %v", tt.t, err, tt.want)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                                DefaultLocale,
+		"*":                               DefaultLocale,
+		"en-US":                           "en-US",
+		"en-US,en;q=0.9":                  "en-US",
+		"fr;q=0.5, zh_tw;q=0.8, en;q=0.3": "zh-TW",
+		"de;q=bad, ja":                    "ja",
+		"en;q=0.8, *;q=0.9":               "en",
+	}
+	for in, want := range tests {
+		if got := ParseAcceptLanguage(in); got != want {
+			t.Errorf("ParseAcceptLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package inbox
+
+import (
+	"time"
+
+	"github.com/r2day/m3s/client/notification"
+	"github.com/r2day/m3s/message/status"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultPageSize 默认每页数量
+	defaultPageSize = 20
+	// maxPageSize 每页最大数量
+	maxPageSize = 100
+)
+
+// row 关联了用户收件箱记录的通知
+type row struct {
+	notification.Model `bson:",inline"`
+	// Inbox 用户对该通知的收件箱记录（最多一条）
+	Inbox []*Model `bson:"inbox"`
+}
+
+// state 用户对通知的收件箱记录，没有时返回 nil
+func (r *row) state() *Model {
+	if len(r.Inbox) == 0 {
+		return nil
+	}
+	return r.Inbox[0]
+}
+
+// item 合并用户的阅读状态，没有收件箱记录时为未读；目标受众不返回给客户端
+func (r *row) item() *Item {
+	result := &Item{Model: r.Model.Public()}
+	if s := r.state(); s != nil && s.Status == status.Read {
+		result.Read = true
+		result.ReadTime = s.StatusTimes[status.Read]
+	}
+	return result
+}
+
+// pageRows 分页查询的结果
+type pageRows struct {
+	Rows  []*row `bson:"rows"`
+	Total []struct {
+		Count int64 `bson:"count"`
+	} `bson:"total"`
+}
+
+// match 用户可见的有效通知：门店广播、发给该用户的通知，且目标受众包含该用户
+// 受众中的标签表达式先取出门店有效通知中出现的表达式，在程序中判断后作为查询条件，filter 为附加的通知查询条件
+func (m *Model) match(storeID string, u *notification.UserContext, filter bson.D, now time.Time) (bson.D, error) {
+	active := notification.ActiveFilter(storeID, now)
+	n := &notification.Model{}
+	values, err := m.Context.Handler.Collection(n.CollectionName()).Distinct(m.Context.Context, "audience.tags", active)
+	if err != nil {
+		return nil, err
+	}
+	exprs := make([]string, 0, len(values))
+	for _, v := range values {
+		if expr, ok := v.(string); ok {
+			exprs = append(exprs, expr)
+		}
+	}
+	return visibleFilter(active, u, exprs, filter), nil
+}
+
+// visibleFilter 合并有效通知、接收用户与受众的查询条件
+func visibleFilter(active bson.D, u *notification.UserContext, exprs []string, filter bson.D) bson.D {
+	userID := ""
+	if u != nil {
+		userID = u.UserID
+	}
+	conditions := bson.A{
+		active,
+		bson.D{{Key: "receiver", Value: bson.D{{Key: "$in", Value: bson.A{nil, "", userID}}}}},
+		u.AudienceFilter(exprs),
+	}
+	if len(filter) > 0 {
+		conditions = append(conditions, filter)
+	}
+	return bson.D{{Key: "$and", Value: conditions}}
+}
+
+// pipeline 按 match 查询通知并关联用户的收件箱记录，排除用户已删除的通知，excludeRead 为 true 时同时排除已读通知
+// 仅投递到收件箱的通知需要有该用户的投递记录；from 为收件箱表，结果按优先级、发布时间倒序
+func pipeline(from string, match bson.D, userID string, excludeRead bool) mongo.Pipeline {
+	hidden := bson.A{status.Deleted}
+	if excludeRead {
+		hidden = append(hidden, status.Read)
+	}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: from},
+			{Key: "let", Value: bson.D{{Key: "id", Value: "$_id"}}},
+			{Key: "pipeline", Value: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{
+					{Key: "user_id", Value: userID},
+					{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$notification_id", "$$id"}}}},
+				}}},
+			}},
+			{Key: "as", Value: "inbox"},
+		}}},
+		{{Key: "$match", Value: bson.D{
+			{Key: "inbox." + status.Field, Value: bson.D{{Key: "$nin", Value: hidden}}},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "delivery", Value: bson.D{{Key: "$ne", Value: notification.DeliveryInbox}}}},
+				bson.D{{Key: "inbox.delivered", Value: true}},
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "priority", Value: -1}, {Key: "publish_time", Value: -1}, {Key: "_id", Value: -1}}}},
+	}
+}
+
+// paged 在查询末尾追加分页，同时返回总数
+func paged(p mongo.Pipeline, skip, limit int64) mongo.Pipeline {
+	return append(p, bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "rows", Value: bson.A{
+			bson.D{{Key: "$skip", Value: skip}},
+			bson.D{{Key: "$limit", Value: limit}},
+		}},
+		{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+	}}})
+}
+
+// aggregate 在通知表中执行查询
+func (m *Model) aggregate(pipeline mongo.Pipeline, results interface{}) error {
+	n := &notification.Model{}
+	coll := m.Context.Handler.Collection(n.CollectionName())
+	cursor, err := coll.Aggregate(m.Context.Context, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(m.Context.Context, results)
+}
+
+// query 用户可见的有效通知的查询
+func (m *Model) query(storeID string, u *notification.UserContext, filter bson.D, excludeRead bool) (mongo.Pipeline, error) {
+	match, err := m.match(storeID, u, filter, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	userID := ""
+	if u != nil {
+		userID = u.UserID
+	}
+	return pipeline(m.Context.Collection, match, userID, excludeRead), nil
+}
+
+// visible 用户可见的全部有效通知
+func (m *Model) visible(storeID string, u *notification.UserContext, filter bson.D, excludeRead bool) ([]*row, error) {
+	p, err := m.query(storeID, u, filter, excludeRead)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*row, 0)
+	if err = m.aggregate(p, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// List 分页获取用户的收件箱（门店广播与个人通知合并，按优先级、发布时间倒序）
+func (m *Model) List(storeID string, u *notification.UserContext, page, pageSize int64) ([]*Item, int64, error) {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	p, err := m.query(storeID, u, nil, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	pages := make([]*pageRows, 0, 1)
+	if err = m.aggregate(paged(p, (page-1)*pageSize, pageSize), &pages); err != nil {
+		return nil, 0, err
+	}
+	results := make([]*Item, 0, pageSize)
+	var total int64
+	if len(pages) > 0 {
+		for _, r := range pages[0].Rows {
+			results = append(results, r.item())
+		}
+		if len(pages[0].Total) > 0 {
+			total = pages[0].Total[0].Count
+		}
+	}
+	return results, total, nil
+}
+
+// UnreadCount 获取用户的未读通知数量
+func (m *Model) UnreadCount(storeID string, u *notification.UserContext) (int64, error) {
+	p, err := m.query(storeID, u, nil, true)
+	if err != nil {
+		return 0, err
+	}
+	counts := make([]struct {
+		Count int64 `bson:"count"`
+	}, 0, 1)
+	if err = m.aggregate(append(p, bson.D{{Key: "$count", Value: "count"}}), &counts); err != nil {
+		return 0, err
+	}
+	if len(counts) == 0 {
+		return 0, nil
+	}
+	return counts[0].Count, nil
+}
+
+// Unread 获取用户未读的有效通知，filter 为附加的通知查询条件；按优先级、发布时间倒序
+func (m *Model) Unread(storeID string, u *notification.UserContext, filter bson.D) ([]*notification.Model, error) {
+	rows, err := m.visible(storeID, u, filter, true)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*notification.Model, 0, len(rows))
+	for _, r := range rows {
+		item := r.Model
+		results = append(results, &item)
+	}
+	return results, nil
+}
+
+// MarkRead 标记通知为已读，已删除的通知不受影响
+// 通知不属于该门店或是发给其他用户的通知时返回 mongo.ErrNoDocuments
+func (m *Model) MarkRead(storeID, userID string, notificationID string) error {
+	objID, err := m.owned(storeID, userID, notificationID)
+	if err != nil {
+		return err
+	}
+	return m.mark(storeID, userID, []primitive.ObjectID{objID}, status.Read)
+}
+
+// MarkAllRead 将用户所有未读的有效通知标记为已读，返回标记的数量
+func (m *Model) MarkAllRead(storeID string, u *notification.UserContext) (int64, error) {
+	rows, err := m.visible(storeID, u, nil, true)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.ID)
+	}
+	if err = m.mark(storeID, u.UserID, ids, status.Read); err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+// DeleteForMe 仅对当前用户删除通知
+// 通知不属于该门店或是发给其他用户的通知时返回 mongo.ErrNoDocuments
+func (m *Model) DeleteForMe(storeID, userID string, notificationID string) error {
+	objID, err := m.owned(storeID, userID, notificationID)
+	if err != nil {
+		return err
+	}
+	return m.mark(storeID, userID, []primitive.ObjectID{objID}, status.Deleted)
+}
+
+// owned 校验通知属于该门店，且为门店广播或发给该用户的通知；仅投递到收件箱的通知需要已投递给该用户
+func (m *Model) owned(storeID, userID, notificationID string) (primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(notificationID)
+	if err != nil {
+		return objID, err
+	}
+	storeObjID, err := primitive.ObjectIDFromHex(storeID)
+	if err != nil {
+		return objID, err
+	}
+	n := &notification.Model{}
+	err = m.Context.Handler.Collection(n.CollectionName()).FindOne(m.Context.Context,
+		ownedFilter(storeObjID, objID, userID),
+		options.FindOne().SetProjection(bson.D{{Key: "delivery", Value: 1}})).Decode(n)
+	if err != nil {
+		return objID, err
+	}
+	if n.Delivery != notification.DeliveryInbox {
+		return objID, nil
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	count, err := coll.CountDocuments(m.Context.Context, deliveredFilter(objID, userID), options.Count().SetLimit(1))
+	if err != nil {
+		return objID, err
+	}
+	if count == 0 {
+		return objID, mongo.ErrNoDocuments
+	}
+	return objID, nil
+}
+
+// deliveredFilter 已投递给用户的收件箱记录
+func deliveredFilter(notificationID primitive.ObjectID, userID string) bson.D {
+	return bson.D{
+		{Key: "notification_id", Value: notificationID},
+		{Key: "user_id", Value: userID},
+		{Key: "delivered", Value: true},
+	}
+}
+
+// ownedFilter 门店中用户可操作的通知：不能操作发给其他用户的通知
+func ownedFilter(storeID, notificationID primitive.ObjectID, userID string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: notificationID},
+		{Key: "meta.merchant_id", Value: storeID},
+		{Key: "receiver", Value: bson.D{{Key: "$in", Value: bson.A{nil, "", userID}}}},
+	}
+}
+
+// Deliver 将通知投递到用户收件箱（未读），已存在的记录只标记为已投递，阅读状态不受影响
+func (m *Model) Deliver(storeID string, notificationID primitive.ObjectID, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	models := make([]mongo.WriteModel, 0, len(userIDs))
+	for _, userID := range userIDs {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "notification_id", Value: notificationID}, {Key: "user_id", Value: userID}}).
+			SetUpdate(bson.D{
+				{Key: "$set", Value: bson.D{{Key: "delivered", Value: true}}},
+				{Key: "$setOnInsert", Value: bson.D{
+					{Key: "store_id", Value: storeID},
+					{Key: status.Field, Value: status.Sent},
+					{Key: status.TimelineField + "." + string(status.Sent), Value: now},
+				}},
+			}).
+			SetUpsert(true))
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.BulkWrite(m.Context.Context, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// mark 批量更新用户对通知的状态（不存在时创建）
+// 已删除的记录不会再被更新
+func (m *Model) mark(storeID, userID string, ids []primitive.ObjectID, to status.Status) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	now := time.Now()
+	models := make([]mongo.WriteModel, 0, len(ids))
+	for _, id := range ids {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{
+				{Key: "notification_id", Value: id},
+				{Key: "user_id", Value: userID},
+				{Key: status.Field, Value: bson.D{{Key: "$ne", Value: status.Deleted}}},
+			}).
+			SetUpdate(bson.D{
+				{Key: "$set", Value: status.SetFields(to, now)},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "store_id", Value: storeID}}},
+			}).
+			SetUpsert(true))
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.BulkWrite(m.Context.Context, models, options.BulkWrite().SetOrdered(false))
+	if mongo.IsDuplicateKeyError(err) {
+		// 已删除的记录不满足条件，upsert 与唯一索引冲突，忽略
+		return nil
+	}
+	return err
+}
+
+// CreateIndexes 创建收件箱索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateMany(m.Context.Context, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "notification_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "store_id", Value: 1}, {Key: "user_id", Value: 1}}},
+	})
+	return err
+}
@@ -0,0 +1,109 @@
+package inbox
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/r2day/m3s/client/notification"
+	"github.com/r2day/m3s/message/status"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRowItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		inbox    []*Model
+		read     bool
+		readTime int64
+	}{
+		{"no record", nil, false, 0},
+		{"delivered", []*Model{{Status: status.Sent, Delivered: true}}, false, 0},
+		{"read", []*Model{{Status: status.Read, StatusTimes: status.Timeline{status.Read: 100}}}, true, 100},
+		{"deleted", []*Model{{Status: status.Deleted}}, false, 0},
+	}
+	for _, tt := range tests {
+		r := &row{Model: notification.Model{Name: "n", Audience: &notification.Audience{Users: []string{"u1", "u2"}}}, Inbox: tt.inbox}
+		got := r.item()
+		if got.Read != tt.read || got.ReadTime != tt.readTime {
+			t.Errorf("%s: item = read %v at %d, want %v at %d", tt.name, got.Read, got.ReadTime, tt.read, tt.readTime)
+		}
+		if got.Name != "n" {
+			t.Errorf("%s: item lost the notification", tt.name)
+		}
+		if got.Audience != nil {
+			t.Errorf("%s: item exposes the audience", tt.name)
+		}
+		// 返回副本，不共享查询结果
+		got.Name = "changed"
+		if r.Name != "n" {
+			t.Errorf("%s: item shares the row", tt.name)
+		}
+	}
+}
+
+func TestOwnedFilter(t *testing.T) {
+	store, id := primitive.NewObjectID(), primitive.NewObjectID()
+	want := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "meta.merchant_id", Value: store},
+		{Key: "receiver", Value: bson.D{{Key: "$in", Value: bson.A{nil, "", "u1"}}}},
+	}
+	if got := ownedFilter(store, id, "u1"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ownedFilter = %v, want %v", got, want)
+	}
+}
+
+func TestDeliveredFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+	want := bson.D{
+		{Key: "notification_id", Value: id},
+		{Key: "user_id", Value: "u1"},
+		{Key: "delivered", Value: true},
+	}
+	if got := deliveredFilter(id, "u1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("deliveredFilter() = %v, want %v", got, want)
+	}
+}
+
+func TestVisibleFilter(t *testing.T) {
+	active := bson.D{{Key: "active", Value: true}}
+	u := &notification.UserContext{UserID: "u1", Tags: []string{"vip"}}
+	extra := bson.D{{Key: "priority", Value: 1}}
+	got := visibleFilter(active, u, []string{"vip"}, extra)
+	want := bson.D{{Key: "$and", Value: bson.A{
+		active,
+		bson.D{{Key: "receiver", Value: bson.D{{Key: "$in", Value: bson.A{nil, "", "u1"}}}}},
+		u.AudienceFilter([]string{"vip"}),
+		extra,
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("visibleFilter = %v, want %v", got, want)
+	}
+}
+
+func TestPipelinePaged(t *testing.T) {
+	match := bson.D{{Key: "x", Value: 1}}
+	p := paged(pipeline("inbox", match, "u1", true), 20, 10)
+	if len(p) != 5 {
+		t.Fatalf("stages = %d, want 5", len(p))
+	}
+	if !reflect.DeepEqual(p[0], bson.D{{Key: "$match", Value: match}}) {
+		t.Errorf("first stage = %v, want the visibility match", p[0])
+	}
+	hidden := bson.D{
+		{Key: "inbox." + status.Field, Value: bson.D{{Key: "$nin", Value: bson.A{status.Deleted, status.Read}}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "delivery", Value: bson.D{{Key: "$ne", Value: notification.DeliveryInbox}}}},
+			bson.D{{Key: "inbox.delivered", Value: true}},
+		}},
+	}
+	if !reflect.DeepEqual(p[2], bson.D{{Key: "$match", Value: hidden}}) {
+		t.Errorf("state stage = %v, want %v", p[2], hidden)
+	}
+	facet := p[4][0].Value.(bson.D)
+	rows := facet[0].Value.(bson.A)
+	if !reflect.DeepEqual(rows, bson.A{bson.D{{Key: "$skip", Value: int64(20)}}, bson.D{{Key: "$limit", Value: int64(10)}}}) {
+		t.Errorf("rows = %v, want skip 20 limit 10", rows)
+	}
+}
@@ -0,0 +1,64 @@
+package inbox
+
+import (
+	"github.com/open4go/model"
+	"github.com/r2day/m3s/client/notification"
+	"github.com/r2day/m3s/message/status"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "client_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_flow"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "inbox"
+)
+
+// Model 用户收件箱
+// 记录每个用户对通知的投递与阅读状态；广播通知仅在用户已读或删除时才会创建记录
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// NotificationID 通知
+	NotificationID primitive.ObjectID `json:"notification_id" bson:"notification_id"`
+	// UserID 用户
+	UserID string `json:"user_id" bson:"user_id"`
+	// StoreID 门店
+	StoreID string `json:"store_id" bson:"store_id,omitempty"`
+	// Status 状态：已投递（未读）、已读、已删除
+	Status status.Status `json:"message_status" bson:"message_status,omitempty"`
+	// StatusTimes 各状态的变更时间
+	StatusTimes status.Timeline `json:"status_times" bson:"status_times,omitempty"`
+	// Delivered 是否由 Deliver 投递，仅投递的记录使用户可见通知；已读、删除产生的记录不影响可见范围
+	Delivered bool `json:"delivered" bson:"delivered,omitempty"`
+}
+
+// Item 收件箱中的一条通知
+type Item struct {
+	// Notification 通知内容
+	*notification.Model
+	// Read 是否已读
+	Read bool `json:"read"`
+	// ReadTime 阅读时间
+	ReadTime int64 `json:"read_time"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
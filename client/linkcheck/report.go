@@ -0,0 +1,101 @@
+package linkcheck
+
+import (
+	"context"
+
+	home "github.com/r2day/m3s/client/home"
+	"github.com/r2day/m3s/client/notification"
+	"github.com/r2day/m3s/client/page"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// SourceNotification 通知
+	SourceNotification = "notification"
+	// SourceHome 主页配置
+	SourceHome = "home"
+)
+
+// BrokenLink 无效的跳转链接
+type BrokenLink struct {
+	// Source 来源：notification, home
+	Source string `json:"source"`
+	// ID 来源记录
+	ID string `json:"id"`
+	// Name 来源记录名称
+	Name string `json:"name"`
+	// Field 所在字段
+	Field string `json:"field"`
+	// PackageType 包类型
+	PackageType string `json:"package_type"`
+	// Url 链接
+	Url string `json:"url"`
+	// Error 校验失败原因
+	Error string `json:"error"`
+}
+
+// Report 门店通知与主页配置中的无效链接
+func Report(ctx context.Context, db *mongo.Database, storeID string) ([]*BrokenLink, error) {
+	p := &page.Model{}
+	p.Init(ctx, db, p.CollectionName())
+	registry, err := p.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &notification.Model{}
+	n.Init(ctx, db, n.CollectionName())
+	notifications, err := n.GetByStoreID(storeID)
+	if err != nil {
+		return nil, err
+	}
+	h := &home.Model{}
+	h.Init(ctx, db, h.CollectionName())
+	configs, err := h.GetByStoreID(storeID)
+	if err != nil {
+		return nil, err
+	}
+	return append(checkNotifications(registry, notifications), checkHomes(registry, configs)...), nil
+}
+
+// checkNotifications 通知中的无效链接，目标受众指定了小程序类型时按这些类型校验
+func checkNotifications(registry *page.Registry, notifications []*notification.Model) []*BrokenLink {
+	results := make([]*BrokenLink, 0)
+	for _, item := range notifications {
+		var appTypes []home.AppType
+		if item.Audience != nil {
+			appTypes = item.Audience.AppTypes
+		}
+		if err := registry.Check(appTypes, item.PackageType, item.Url); err != nil {
+			results = append(results, &BrokenLink{
+				Source:      SourceNotification,
+				ID:          item.ID.Hex(),
+				Name:        item.Name,
+				Field:       "url",
+				PackageType: item.PackageType,
+				Url:         item.Url,
+				Error:       err.Error(),
+			})
+		}
+	}
+	return results
+}
+
+// checkHomes 主页配置中的无效链接
+func checkHomes(registry *page.Registry, configs []*home.Model) []*BrokenLink {
+	results := make([]*BrokenLink, 0)
+	for _, config := range configs {
+		for _, link := range config.CheckLinks(registry) {
+			results = append(results, &BrokenLink{
+				Source:      SourceHome,
+				ID:          config.ID.Hex(),
+				Name:        config.Name,
+				Field:       link.Field,
+				PackageType: link.PackageType,
+				Url:         link.Url,
+				Error:       link.Error,
+			})
+		}
+	}
+	return results
+}
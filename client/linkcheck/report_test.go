@@ -0,0 +1,82 @@
+package linkcheck
+
+import (
+	"reflect"
+	"testing"
+
+	home "github.com/r2day/m3s/client/home"
+	"github.com/r2day/m3s/client/notification"
+	"github.com/r2day/m3s/client/page"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// registry 微信与支付宝都登记了首页，只有微信登记了菜单页
+func registry() *page.Registry {
+	return page.NewRegistry([]*page.Model{
+		{AppType: home.WxApp, PackageType: "main", Path: "pages/index/index"},
+		{AppType: home.WxApp, PackageType: "main", Path: "pages/menu/menu"},
+		{AppType: home.AlipayApp, PackageType: "main", Path: "pages/index/index"},
+	})
+}
+
+func TestCheckNotifications(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *notification.Model
+		want bool
+	}{
+		{"no url", &notification.Model{PackageType: "main"}, false},
+		{"registered", &notification.Model{PackageType: "main", Url: "pages/menu/menu"}, false},
+		{"not registered", &notification.Model{PackageType: "main", Url: "pages/cart/cart"}, true},
+		{"missing for audience app type", &notification.Model{
+			PackageType: "main",
+			Url:         "pages/menu/menu",
+			Audience:    &notification.Audience{AppTypes: []home.AppType{home.AlipayApp}},
+		}, true},
+		{"unregistered package skipped", &notification.Model{PackageType: "public", Url: "pages/cart/cart"}, false},
+		{"external", &notification.Model{PackageType: "main", Url: "https://example.com"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.n.ID = primitive.NewObjectID()
+			got := checkNotifications(registry(), []*notification.Model{tt.n})
+			if (len(got) > 0) != tt.want {
+				t.Fatalf("checkNotifications() = %d links, want broken %v", len(got), tt.want)
+			}
+			if tt.want && (got[0].Source != SourceNotification || got[0].ID != tt.n.ID.Hex() || got[0].Url != tt.n.Url || got[0].Error == "") {
+				t.Errorf("link = %+v", got[0])
+			}
+		})
+	}
+}
+
+func TestCheckHomes(t *testing.T) {
+	alipay := home.AlipayApp
+	show := []*home.ImageDisplayConfig{
+		{PackageType: "main", Url: "pages/index/index"},
+		{PackageType: "main", Url: "pages/menu/menu"},
+	}
+	tests := []struct {
+		name   string
+		config *home.Model
+		want   []string
+	}{
+		{"default wechat", &home.Model{RecommendShowList: show}, []string{}},
+		{"alipay", &home.Model{Type: &alipay, RecommendShowList: show}, []string{"recommend_show_list.1.url"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.ID = primitive.NewObjectID()
+			got := make([]string, 0)
+			for _, link := range checkHomes(registry(), []*home.Model{tt.config}) {
+				if link.Source != SourceHome || link.ID != tt.config.ID.Hex() {
+					t.Errorf("link = %+v", link)
+				}
+				got = append(got, link.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkHomes() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
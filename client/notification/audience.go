@@ -0,0 +1,302 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	home "github.com/r2day/m3s/client/home"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CustomerType 新老客
+type CustomerType string
+
+const (
+	// AnyCustomer 不限
+	AnyCustomer CustomerType = ""
+	// NewCustomer 新客（未下过单）
+	NewCustomer CustomerType = "new"
+	// ReturningCustomer 老客（下过单）
+	ReturningCustomer CustomerType = "returning"
+)
+
+// ErrTagExpr 标签表达式错误
+var ErrTagExpr = errors.New("invalid tag expression")
+
+// Audience 通知的目标受众
+// 各条件之间为且的关系，未设置的条件不限制；Audience 为空时门店所有用户可见
+type Audience struct {
+	// MemberLevels 会员等级
+	MemberLevels []string `json:"member_levels" bson:"member_levels,omitempty"`
+	// Customer 新老客
+	Customer CustomerType `json:"customer" bson:"customer,omitempty"`
+	// AppTypes 小程序类型
+	AppTypes []home.AppType `json:"app_types" bson:"app_types,omitempty"`
+	// PackageTypes 小程序包类型：main,private,public
+	PackageTypes []string `json:"package_types" bson:"package_types,omitempty"`
+	// Users 指定用户
+	Users []string `json:"users" bson:"users,omitempty"`
+	// Tags 用户标签表达式，支持 && || ! 及括号，例如：vip && (coffee || !tea)
+	Tags string `json:"tags" bson:"tags,omitempty"`
+}
+
+// UserContext 判断受众时使用的用户信息
+type UserContext struct {
+	// UserID 用户
+	UserID string
+	// MemberLevel 会员等级
+	MemberLevel string
+	// IsNew 是否新客
+	IsNew bool
+	// AppType 小程序类型
+	AppType home.AppType
+	// PackageType 小程序包类型
+	PackageType string
+	// Tags 用户标签
+	Tags []string
+}
+
+// Validate 校验受众设置
+func (a *Audience) Validate() error {
+	if a == nil {
+		return nil
+	}
+	switch a.Customer {
+	case AnyCustomer, NewCustomer, ReturningCustomer:
+	default:
+		return fmt.Errorf("unknown customer type %q", a.Customer)
+	}
+	_, err := parseTags(a.Tags)
+	return err
+}
+
+// Match 判断用户是否属于目标受众
+// 标签表达式无法解析时视为不匹配
+func (a *Audience) Match(u *UserContext) bool {
+	if a == nil {
+		return true
+	}
+	if u == nil {
+		u = &UserContext{}
+	}
+	if len(a.MemberLevels) > 0 && !contains(a.MemberLevels, u.MemberLevel) {
+		return false
+	}
+	if a.Customer == NewCustomer && !u.IsNew || a.Customer == ReturningCustomer && u.IsNew {
+		return false
+	}
+	if len(a.AppTypes) > 0 && !contains(a.AppTypes, u.AppType) {
+		return false
+	}
+	if len(a.PackageTypes) > 0 && !contains(a.PackageTypes, u.PackageType) {
+		return false
+	}
+	if len(a.Users) > 0 && !contains(a.Users, u.UserID) {
+		return false
+	}
+	return u.matchTags(a.Tags)
+}
+
+// matchTags 判断用户标签是否满足标签表达式，表达式为空时匹配，无法解析时不匹配
+func (u *UserContext) matchTags(s string) bool {
+	if strings.TrimSpace(s) == "" {
+		return true
+	}
+	expr, err := parseTags(s)
+	if err != nil {
+		return false
+	}
+	tags := make(map[string]bool, len(u.Tags))
+	for _, tag := range u.Tags {
+		tags[tag] = true
+	}
+	return expr.eval(tags)
+}
+
+// AudienceFilter 受众包含该用户的通知查询条件
+// 标签表达式无法转换为查询条件，exprs 为候选通知中的标签表达式，在此处逐一判断后只保留匹配的表达式
+func (u *UserContext) AudienceFilter(exprs []string) bson.D {
+	if u == nil {
+		u = &UserContext{}
+	}
+	tags := bson.A{nil, ""}
+	for _, expr := range exprs {
+		if expr != "" && u.matchTags(expr) {
+			tags = append(tags, expr)
+		}
+	}
+	customer := bson.A{nil, AnyCustomer, ReturningCustomer}
+	if u.IsNew {
+		customer = bson.A{nil, AnyCustomer, NewCustomer}
+	}
+	// 数组字段为空时不会写入（omitempty），null 同时匹配字段不存在与受众为空的通知
+	return bson.D{
+		{Key: "audience.member_levels", Value: bson.D{{Key: "$in", Value: bson.A{nil, u.MemberLevel}}}},
+		{Key: "audience.customer", Value: bson.D{{Key: "$in", Value: customer}}},
+		{Key: "audience.app_types", Value: bson.D{{Key: "$in", Value: bson.A{nil, u.AppType}}}},
+		{Key: "audience.package_types", Value: bson.D{{Key: "$in", Value: bson.A{nil, u.PackageType}}}},
+		{Key: "audience.users", Value: bson.D{{Key: "$in", Value: bson.A{nil, u.UserID}}}},
+		{Key: "audience.tags", Value: bson.D{{Key: "$in", Value: tags}}},
+	}
+}
+
+// Visible 判断通知对用户是否可见：个人通知仅接收用户可见，其余按受众判断
+func (m *Model) Visible(u *UserContext) bool {
+	if m.Receiver != "" && (u == nil || m.Receiver != u.UserID) {
+		return false
+	}
+	return m.Audience.Match(u)
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// tagExpr 标签表达式节点
+type tagExpr struct {
+	op    string
+	tag   string
+	left  *tagExpr
+	right *tagExpr
+}
+
+func (e *tagExpr) eval(tags map[string]bool) bool {
+	switch e.op {
+	case "&&":
+		return e.left.eval(tags) && e.right.eval(tags)
+	case "||":
+		return e.left.eval(tags) || e.right.eval(tags)
+	case "!":
+		return !e.left.eval(tags)
+	}
+	return tags[e.tag]
+}
+
+// tagParser 标签表达式解析
+// expr := and ('||' and)* ; and := unary ('&&' unary)* ; unary := '!' unary | '(' expr ')' | tag
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseTags(s string) (*tagExpr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	tokens, err := tokenizeTags(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &tagParser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrTagExpr, p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+func tokenizeTags(s string) ([]string, error) {
+	tokens := make([]string, 0)
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t':
+			i++
+		case r == '(' || r == ')' || r == '!':
+			tokens = append(tokens, string(r))
+			i++
+		case r == '&' || r == '|':
+			if i+1 >= len(runes) || runes[i+1] != r {
+				return nil, fmt.Errorf("%w: single %q", ErrTagExpr, r)
+			}
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		default:
+			start := i
+			for i < len(runes) && !strings.ContainsRune(" \t()!&|", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		}
+	}
+	return tokens, nil
+}
+
+func (p *tagParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagParser) or() (*tagExpr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &tagExpr{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *tagParser) and() (*tagExpr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &tagExpr{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *tagParser) unary() (*tagExpr, error) {
+	switch token := p.peek(); token {
+	case "":
+		return nil, fmt.Errorf("%w: unexpected end", ErrTagExpr)
+	case "!":
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &tagExpr{op: "!", left: operand}, nil
+	case "(":
+		p.pos++
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("%w: missing )", ErrTagExpr)
+		}
+		p.pos++
+		return expr, nil
+	case ")", "&&", "||":
+		return nil, fmt.Errorf("%w: unexpected %q", ErrTagExpr, token)
+	default:
+		p.pos++
+		return &tagExpr{tag: token}, nil
+	}
+}
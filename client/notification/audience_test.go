@@ -0,0 +1,144 @@
+package notification
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	home "github.com/r2day/m3s/client/home"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAudienceMatch(t *testing.T) {
+	vip := &UserContext{
+		UserID:      "u1",
+		MemberLevel: "gold",
+		AppType:     home.WxApp,
+		PackageType: "main",
+		Tags:        []string{"vip", "coffee"},
+	}
+	newUser := &UserContext{UserID: "u2", IsNew: true, AppType: home.AlipayApp, Tags: []string{"tea"}}
+
+	tests := []struct {
+		name string
+		a    *Audience
+		u    *UserContext
+		want bool
+	}{
+		{"nil audience", nil, vip, true},
+		{"empty audience", &Audience{}, newUser, true},
+		{"nil user", &Audience{}, nil, true},
+		{"member level", &Audience{MemberLevels: []string{"gold", "silver"}}, vip, true},
+		{"member level mismatch", &Audience{MemberLevels: []string{"silver"}}, vip, false},
+		{"new customer", &Audience{Customer: NewCustomer}, newUser, true},
+		{"new customer mismatch", &Audience{Customer: NewCustomer}, vip, false},
+		{"returning customer", &Audience{Customer: ReturningCustomer}, vip, true},
+		{"returning customer mismatch", &Audience{Customer: ReturningCustomer}, newUser, false},
+		{"app type", &Audience{AppTypes: []home.AppType{home.AlipayApp}}, newUser, true},
+		{"app type mismatch", &Audience{AppTypes: []home.AppType{home.DouYin}}, vip, false},
+		{"package type", &Audience{PackageTypes: []string{"main"}}, vip, true},
+		{"package type mismatch", &Audience{PackageTypes: []string{"private"}}, newUser, false},
+		{"users", &Audience{Users: []string{"u1"}}, vip, true},
+		{"users mismatch", &Audience{Users: []string{"u1"}}, newUser, false},
+		{"nil user with condition", &Audience{Users: []string{"u1"}}, nil, false},
+		{"tag", &Audience{Tags: "vip"}, vip, true},
+		{"tag and", &Audience{Tags: "vip && coffee"}, vip, true},
+		{"tag and mismatch", &Audience{Tags: "vip && tea"}, vip, false},
+		{"tag or", &Audience{Tags: "vip || tea"}, newUser, true},
+		{"tag not", &Audience{Tags: "!vip"}, newUser, true},
+		{"tag not mismatch", &Audience{Tags: "!vip"}, vip, false},
+		{"tag precedence", &Audience{Tags: "tea || vip && !coffee"}, vip, false},
+		{"tag parentheses", &Audience{Tags: "(tea || vip) && !coffee"}, newUser, true},
+		{"invalid tags", &Audience{Tags: "vip &&"}, vip, false},
+		{"all conditions", &Audience{MemberLevels: []string{"gold"}, Customer: ReturningCustomer, AppTypes: []home.AppType{home.WxApp}, Tags: "coffee"}, vip, true},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Match(tt.u); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAudienceValidate(t *testing.T) {
+	tests := []struct {
+		a       *Audience
+		wantErr error
+	}{
+		{nil, nil},
+		{&Audience{Customer: NewCustomer, Tags: "a && (b || !c)"}, nil},
+		{&Audience{Tags: "a & b"}, ErrTagExpr},
+		{&Audience{Tags: "(a || b"}, ErrTagExpr},
+		{&Audience{Tags: "a b"}, ErrTagExpr},
+		{&Audience{Tags: "|| a"}, ErrTagExpr},
+	}
+	for _, tt := range tests {
+		err := tt.a.Validate()
+		if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.a, err, tt.wantErr)
+		}
+	}
+	if err := (&Audience{Customer: "vip"}).Validate(); err == nil {
+		t.Error("unknown customer type should be rejected")
+	}
+}
+
+func TestVisible(t *testing.T) {
+	u := &UserContext{UserID: "u1"}
+	tests := []struct {
+		name string
+		m    *Model
+		u    *UserContext
+		want bool
+	}{
+		{"broadcast", &Model{}, u, true},
+		{"receiver", &Model{Receiver: "u1"}, u, true},
+		{"other receiver", &Model{Receiver: "u2"}, u, false},
+		{"receiver without user", &Model{Receiver: "u1"}, nil, false},
+		{"audience", &Model{Audience: &Audience{Users: []string{"u2"}}}, u, false},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Visible(tt.u); got != tt.want {
+			t.Errorf("%s: Visible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAudienceFilter(t *testing.T) {
+	in := func(values ...interface{}) bson.D {
+		return bson.D{{Key: "$in", Value: bson.A(values)}}
+	}
+	u := &UserContext{UserID: "u1", MemberLevel: "gold", AppType: home.AlipayApp, PackageType: "main", Tags: []string{"vip"}}
+	got := u.AudienceFilter([]string{"vip", "tea", "vip && !tea", "(", ""})
+	want := bson.D{
+		{Key: "audience.member_levels", Value: in(nil, "gold")},
+		{Key: "audience.customer", Value: in(nil, AnyCustomer, ReturningCustomer)},
+		{Key: "audience.app_types", Value: in(nil, home.AlipayApp)},
+		{Key: "audience.package_types", Value: in(nil, "main")},
+		{Key: "audience.users", Value: in(nil, "u1")},
+		// 只保留与用户标签匹配的表达式，无法解析的表达式不匹配
+		{Key: "audience.tags", Value: in(nil, "", "vip", "vip && !tea")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("AudienceFilter = %v, want %v", got, want)
+	}
+
+	newUser := &UserContext{IsNew: true}
+	if got := newUser.AudienceFilter(nil)[1]; !reflect.DeepEqual(got.Value, in(nil, AnyCustomer, NewCustomer)) {
+		t.Errorf("new customer filter = %v", got.Value)
+	}
+	var nilUser *UserContext
+	if got := nilUser.AudienceFilter(nil)[4]; !reflect.DeepEqual(got.Value, in(nil, "")) {
+		t.Errorf("nil user filter = %v", got.Value)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	m := &Model{Name: "n", Audience: &Audience{Users: []string{"u1", "u2"}, Tags: "vip"}}
+	got := m.Public()
+	if got.Audience != nil || got.Name != "n" {
+		t.Fatalf("Public = %+v, want the audience removed", got)
+	}
+	if m.Audience == nil {
+		t.Fatal("Public modified the notification")
+	}
+}
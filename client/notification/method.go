@@ -2,9 +2,51 @@ package notification
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/open4go/log"
+	"github.com/open4go/model"
+	home "github.com/r2day/m3s/client/home"
+	"github.com/r2day/m3s/client/page"
+	"github.com/r2day/m3s/message/ratelimit"
+	"github.com/r2day/m3s/message/status"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ActiveFilter 门店在 now 时刻处于展示期内的通知
+// 已发布（发布时间已到）且未结束（结束时间为空或晚于 now）
+// 通过通用接口创建的通知没有状态与发布时间，视为已发布
+func ActiveFilter(storeID string, now time.Time) bson.D {
+	objID, _ := primitive.ObjectIDFromHex(storeID)
+	return bson.D{
+		{Key: "meta.merchant_id", Value: objID},
+		{Key: status.Field, Value: status.In(status.Sent)},
+		{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "publish_time", Value: bson.D{{Key: "$exists", Value: false}}}},
+				bson.D{{Key: "publish_time", Value: bson.D{{Key: "$lte", Value: now.Unix()}}}},
+			}}},
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "end_time", Value: bson.D{{Key: "$exists", Value: false}}}},
+				bson.D{{Key: "end_time", Value: bson.D{{Key: "$gt", Value: now.Unix()}}}},
+			}}},
+		}},
+	}
+}
+
+// ErrConversationType 好友申请与私信由 conversation 包处理，不再写入通知表
+var ErrConversationType = errors.New("friend requests and private messages belong to conversations")
+
+const (
+	// archiveBatchSize 每批归档的数量
+	archiveBatchSize = 500
+	// defaultArchiveAfter 默认通知结束后保留在主表的时长
+	defaultArchiveAfter = 30 * 24 * time.Hour
 )
 
 func (m *Model) GetByStoreID(id string) ([]*Model, error) {
@@ -29,3 +71,390 @@ func (m *Model) GetByStoreID(id string) ([]*Model, error) {
 	}
 	return results, nil
 }
+
+// ListActive 获取门店在 now 时刻展示期内的通知
+// packageType 不为空时仅返回该小程序包及未指定小程序包的通知；按优先级、发布时间倒序
+func (m *Model) ListActive(storeID string, now time.Time, packageType string) ([]*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := ActiveFilter(storeID, now)
+	if packageType != "" {
+		filter = append(filter, bson.E{Key: "package_type", Value: bson.D{
+			{Key: "$in", Value: bson.A{packageType, "", nil}},
+		}})
+	}
+	opt := options.Find().SetSort(bson.D{
+		{Key: "priority", Value: -1},
+		{Key: "publish_time", Value: -1},
+		{Key: "_id", Value: -1},
+	})
+
+	results := make([]*Model, 0)
+	cursor, err := coll.Find(m.Context.Context, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListActiveFor 获取用户在 now 时刻可见的有效通知
+// 在 ListActive 的基础上按接收用户与目标受众过滤
+func (m *Model) ListActiveFor(storeID string, now time.Time, u *UserContext) ([]*Model, error) {
+	packageType := ""
+	if u != nil {
+		packageType = u.PackageType
+	}
+	items, err := m.ListActive(storeID, now, packageType)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Model, 0, len(items))
+	for _, item := range items {
+		if item.Visible(u) {
+			results = append(results, item)
+		}
+	}
+	return results, nil
+}
+
+// Archive 将结束时间早于 now - keep 的通知移入归档表，返回归档的数量；keep 为 0 时保留 30 天
+// 先写入归档表再从主表删除，中途失败可重复执行
+func (m *Model) Archive(now time.Time, keep time.Duration) (int64, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	archive := m.Context.Handler.Collection(m.ArchiveCollectionName())
+	if keep <= 0 {
+		keep = defaultArchiveAfter
+	}
+	filter := bson.D{{Key: "end_time", Value: bson.D{
+		{Key: "$gt", Value: 0},
+		{Key: "$lt", Value: now.Add(-keep).Unix()},
+	}}}
+
+	var total int64
+	for {
+		cursor, err := coll.Find(m.Context.Context, filter, options.Find().SetLimit(archiveBatchSize))
+		if err != nil {
+			return total, err
+		}
+		docs := make([]bson.Raw, 0)
+		if err = cursor.All(m.Context.Context, &docs); err != nil {
+			return total, err
+		}
+		if len(docs) == 0 {
+			return total, nil
+		}
+
+		ids := make(bson.A, 0, len(docs))
+		rows := make([]interface{}, 0, len(docs))
+		for _, doc := range docs {
+			ids = append(ids, doc.Lookup("_id"))
+			rows = append(rows, doc)
+		}
+		_, err = archive.InsertMany(m.Context.Context, rows, options.InsertMany().SetOrdered(false))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			// 重复说明上次已写入归档表但未删除
+			return total, err
+		}
+		result, err := coll.DeleteMany(m.Context.Context, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+		if err != nil {
+			return total, err
+		}
+		total += result.DeletedCount
+		if len(docs) < archiveBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// IncClick 原子增加点击数
+func (m *Model) IncClick(id string, n int64) error {
+	return m.inc(id, "click_count", n)
+}
+
+// IncImpression 原子增加曝光数
+func (m *Model) IncImpression(id string, n int64) error {
+	return m.inc(id, "impression_count", n)
+}
+
+// inc 原子增加计数字段
+func (m *Model) inc(id string, field string, n int64) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	result, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: objID}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: field, Value: n}}}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// CreateIndexes 创建通知索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateMany(m.Context.Context, []mongo.IndexModel{
+		{Keys: bson.D{
+			{Key: "meta.merchant_id", Value: 1},
+			{Key: status.Field, Value: 1},
+			{Key: "publish_time", Value: -1},
+		}},
+		{Keys: bson.D{{Key: "end_time", Value: 1}}},
+		{Keys: bson.D{{Key: "publish_time", Value: 1}, {Key: "pushed_time", Value: 1}}},
+	})
+	return err
+}
+
+// UpdateStatus 原子更新通知状态：仅当当前状态为 from 时更新为 to
+func (m *Model) UpdateStatus(from, to status.Status) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{{Key: "_id", Value: m.ID}}
+	if err := status.Update(m.Context.Context, coll, filter, from, to, nil); err != nil {
+		return err
+	}
+	m.Status = to
+	if m.StatusTimes == nil {
+		m.StatusTimes = status.Timeline{}
+	}
+	m.StatusTimes[to] = time.Now().Unix()
+	return nil
+}
+
+// MigrateLegacyStatus 将旧版本的整数状态迁移为字符串状态
+func (m *Model) MigrateLegacyStatus() (int64, error) {
+	return status.MigrateLegacy(m.Context.Context, m.Context.Handler.Collection(m.Context.Collection))
+}
+
+// ValidateText 校验多语言文本包含默认语言
+func (m *Model) ValidateText() error {
+	if err := m.Title.Validate(); err != nil {
+		return fmt.Errorf("title: %w", err)
+	}
+	if err := m.Content.Validate(); err != nil {
+		return fmt.Errorf("content: %w", err)
+	}
+	return nil
+}
+
+// ValidateLink 校验跳转链接是否为包类型下已登记的页面
+// 设置了目标小程序类型时每个类型都需要登记该页面
+func (m *Model) ValidateLink() error {
+	return m.checkLink(m)
+}
+
+// Validate 校验受众、多语言文本与跳转链接
+func (m *Model) Validate() error {
+	return m.validate(m)
+}
+
+// Create 校验后保存通知
+func (m *Model) Create(d interface{}) (string, error) {
+	if n, ok := d.(*Model); ok {
+		if err := m.validate(n); err != nil {
+			return "", err
+		}
+	}
+	return m.Model.Create(d)
+}
+
+// Update 校验后更新通知
+// 只更新部分字段时，跳转链接结合已保存的包类型、链接与受众校验
+func (m *Model) Update(d interface{}, id string) error {
+	if n, ok := d.(*Model); ok {
+		merged := *n
+		if n.Url != "" || n.PackageType != "" || n.Audience != nil {
+			saved := &Model{}
+			err := m.Model.GetOne(saved, id)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+			if merged.PackageType == "" {
+				merged.PackageType = saved.PackageType
+			}
+			if merged.Url == "" {
+				merged.Url = saved.Url
+			}
+			if merged.Audience == nil {
+				merged.Audience = saved.Audience
+			}
+		}
+		if err := m.validate(&merged); err != nil {
+			return err
+		}
+	}
+	return m.Model.Update(d, id)
+}
+
+func (m *Model) validate(n *Model) error {
+	if err := n.Audience.Validate(); err != nil {
+		return err
+	}
+	if err := n.ValidateText(); err != nil {
+		return err
+	}
+	return m.checkLink(n)
+}
+
+// checkLink 使用 m 的数据库连接校验 n 的跳转链接
+func (m *Model) checkLink(n *Model) error {
+	if n.Url == "" {
+		return nil
+	}
+	var appTypes []home.AppType
+	if n.Audience != nil {
+		appTypes = n.Audience.AppTypes
+	}
+	p := &page.Model{}
+	p.Init(m.Context.Context, m.Context.Handler, p.CollectionName())
+	return p.Check(appTypes, n.PackageType, n.Url)
+}
+
+// Public 返回发送给客户端的副本，去掉目标受众（包含其他用户与标签）
+func (m *Model) Public() *Model {
+	result := *m
+	result.Audience = nil
+	return &result
+}
+
+// Localize 返回按请求语言解析文本后的副本
+func (m *Model) Localize(locale string) *Model {
+	result := *m
+	result.Title = m.Title.Only(locale)
+	result.Content = m.Content.Only(locale)
+	return &result
+}
+
+// PublishOptions 发布选项
+type PublishOptions struct {
+	// RateLimits 发布时的频率限制，为空时不限制
+	// 接收者为通知的接收用户（广播通知为门店），模版为通知类型
+	RateLimits []ratelimit.Rule
+	// Pusher 发布后的实时推送，为空时不推送
+	Pusher Pusher
+}
+
+// Publish 使用默认选项发布通知，见 PublishWith
+func (m *Model) Publish() (string, error) {
+	return m.PublishWith(nil)
+}
+
+// PublishWith 发布通知，仅支持系统通知，opt 为空时使用默认选项
+// 超出频率限制的通知标记为已抑制并保留记录，不会展示给用户
+func (m *Model) PublishWith(opt *PublishOptions) (string, error) {
+	if opt == nil {
+		opt = &PublishOptions{}
+	}
+	if m.Type == FriendRequest || m.Type == PrivateMessage {
+		return "", ErrConversationType
+	}
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if m.PublishTime == 0 {
+		m.PublishTime = now.Unix()
+	}
+	m.Status = status.Sent
+	m.StatusTimes = status.Timeline{status.Sent: now.Unix()}
+
+	receiver := m.Receiver
+	if receiver == "" {
+		receiver = model.GetValueFromCtx(m.Context.Context, model.MerchantKey)
+	}
+	limiter := &ratelimit.Model{}
+	limiter.Init(m.Context.Context, m.Context.Handler, limiter.CollectionName())
+	rule, err := limiter.Allow(modelName, opt.RateLimits, ratelimit.Subject{
+		Receiver: receiver,
+		Template: strconv.Itoa(int(m.Type)),
+	})
+	if err != nil {
+		return "", err
+	}
+	if rule != nil {
+		m.Status = status.Suppressed
+		m.StatusTimes = status.Timeline{status.Suppressed: now.Unix()}
+	}
+
+	// 预先生成编号，推送事件使用该编号作为断线重放的位置
+	m.ID = primitive.NewObjectID()
+	push := opt.Pusher != nil && m.Delivery != DeliveryInbox && m.Status == status.Sent && m.PublishTime <= now.Unix()
+	if push {
+		m.PushedTime = now.Unix()
+	}
+	// 已在发布前校验，直接保存
+	id, err := m.Model.Create(m)
+	if err != nil {
+		return "", err
+	}
+	if push {
+		// 通知已保存，推送失败不影响发布，用户仍可在收件箱中看到
+		if err = opt.Pusher.Publish(m.Context.Context, m.Meta.MerchantID, m); err != nil {
+			log.Log(m.Context.Context).WithField("notification", id).Error(err)
+		}
+	}
+	return id, nil
+}
+
+// SetDelivery 设置门店通知的投递方式，通知不属于该门店时返回 mongo.ErrNoDocuments
+func (m *Model) SetDelivery(storeID, id string, delivery Delivery) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	storeObjID, err := primitive.ObjectIDFromHex(storeID)
+	if err != nil {
+		return err
+	}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	result, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: objID}, {Key: "meta.merchant_id", Value: storeObjID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "delivery", Value: delivery}}}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ClaimDue 领取已到发布时间但尚未推送的通知（最多 limit 条），并记录推送时间
+// 发布时间早于 now - maxDelay 的通知不再推送，避免服务长时间停止后推送过时的通知
+// 多个实例同时领取时每条通知只会被一个实例领取；仅投递到收件箱的通知不推送
+func (m *Model) ClaimDue(now time.Time, maxDelay time.Duration, limit int) ([]*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{
+		{Key: status.Field, Value: status.Sent},
+		{Key: "publish_time", Value: bson.D{
+			{Key: "$gt", Value: now.Add(-maxDelay).Unix()},
+			{Key: "$lte", Value: now.Unix()},
+		}},
+		{Key: "pushed_time", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "delivery", Value: bson.D{{Key: "$ne", Value: DeliveryInbox}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "pushed_time", Value: now.Unix()}}}}
+	opt := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "publish_time", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	results := make([]*Model, 0)
+	for len(results) < limit {
+		n := &Model{}
+		err := coll.FindOneAndUpdate(m.Context.Context, filter, update, opt).Decode(n)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return results, err
+		}
+		results = append(results, n)
+	}
+	return results, nil
+}
@@ -0,0 +1,33 @@
+package notification
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/r2day/m3s/client/i18n"
+)
+
+func TestLocalizeJSON(t *testing.T) {
+	n := &Model{
+		Title:   i18n.Text{"zh-CN": "你好", "en": "Hello"},
+		Content: i18n.Text{"zh-CN": "内容"},
+	}
+	full, _ := json.Marshal(n)
+	var decoded Model
+	if err := json.Unmarshal(full, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Title, n.Title) {
+		t.Errorf("round trip title = %v, want %v", decoded.Title, n.Title)
+	}
+
+	localized, _ := json.Marshal(n.Localize("en-US"))
+	var fields map[string]interface{}
+	if err := json.Unmarshal(localized, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["title"] != "Hello" || fields["content"] != "内容" {
+		t.Errorf("localized title, content = %v, %v", fields["title"], fields["content"])
+	}
+}
@@ -1,7 +1,11 @@
 package notification
 
 import (
+	"context"
+
 	"github.com/open4go/model"
+	"github.com/r2day/m3s/client/i18n"
+	"github.com/r2day/m3s/message/status"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -15,21 +19,29 @@ const (
 	collectionNameSuffix = "_config"
 	// 这个需要用户根据具体业务完成设定
 	modelName = "notification"
+	// archiveSuffix 归档表后缀
+	archiveSuffix = "_archive"
+)
+
+// MessageStatus 消息状态
+type MessageStatus = status.Status
+
+// Deprecated: 使用 status 包中的状态。
+// 旧版本的 Unread 与 Sent 含义相同（已发送未读），已移除，请使用 status.Sent
+const (
+	Sent    = status.Sent
+	Read    = status.Read
+	Deleted = status.Deleted
 )
 
-// MessageStatus represents the status of the message
-type MessageStatus int
+// Delivery 通知的投递方式
+type Delivery string
 
 const (
-	// Sent represents a message status of sent
-	Sent MessageStatus = iota
-	// Read represents a message status of read
-	Read
-	// Unread represents a message status of unread
-	Unread
-	// Deleted represents a message status of deleted
-	Deleted
-	// Add more statuses as needed
+	// DeliveryAudience 按接收用户与目标受众展示（默认）
+	DeliveryAudience Delivery = ""
+	// DeliveryInbox 仅展示给已投递到收件箱的用户，由分发任务投递，不做实时推送
+	DeliveryInbox Delivery = "inbox"
 )
 
 // MessageType represents the type of the message
@@ -39,8 +51,12 @@ const (
 	// SystemNotification represents a system notification message type
 	SystemNotification MessageType = iota
 	// FriendRequest represents a friend request message type
+	//
+	// Deprecated: handled by the conversation package
 	FriendRequest
 	// PrivateMessage represents a private message type
+	//
+	// Deprecated: handled by the conversation package
 	PrivateMessage
 	// Add more types as needed
 )
@@ -54,12 +70,18 @@ type Model struct {
 	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 
 	// Name 名称
-	Name     string        `json:"name" bson:"name,omitempty"`
-	SenderID string        `json:"sender_id" bson:"sender_id,omitempty"`
-	Title    string        `json:"title" bson:"title,omitempty"`
-	Content  string        `json:"content" bson:"content,omitempty"`
-	Type     MessageType   `json:"type" bson:"type,omitempty"`
-	Status   MessageStatus `json:"message_status" bson:"message_status,omitempty"`
+	Name     string `json:"name" bson:"name,omitempty"`
+	SenderID string `json:"sender_id" bson:"sender_id,omitempty"`
+	// Receiver 接收用户，为空时发送给门店所有用户
+	Receiver string `json:"receiver" bson:"receiver,omitempty"`
+	// Title 标题（多语言）
+	Title i18n.Text `json:"title" bson:"title,omitempty"`
+	// Content 内容（多语言）
+	Content i18n.Text     `json:"content" bson:"content,omitempty"`
+	Type    MessageType   `json:"type" bson:"type,omitempty"`
+	Status  status.Status `json:"message_status" bson:"message_status,omitempty"`
+	// StatusTimes 各状态的变更时间
+	StatusTimes status.Timeline `json:"status_times" bson:"status_times,omitempty"`
 	// 小程序包类型：main,private,public
 	PackageType string `json:"package_type" bson:"package_type,omitempty"`
 	// 小程序跳转url
@@ -68,8 +90,24 @@ type Model struct {
 	PublishTime int64 `json:"publish_time" bson:"publish_time,omitempty"`
 	// EndTime 结束时间
 	EndTime int64 `json:"end_time" bson:"end_time,omitempty"`
+	// Audience 目标受众，为空时门店所有用户可见
+	Audience *Audience `json:"audience" bson:"audience,omitempty"`
+	// Delivery 投递方式，为空时按接收用户与目标受众展示
+	Delivery Delivery `json:"delivery" bson:"delivery,omitempty"`
+	// Priority 优先级，数值越大越靠前
+	Priority int `json:"priority" bson:"priority,omitempty"`
 	// 点击数 click_count
 	ClickCount int64 `json:"click_count" bson:"click_count,omitempty"`
+	// ImpressionCount 曝光数
+	ImpressionCount int64 `json:"impression_count" bson:"impression_count,omitempty"`
+	// PushedTime 实时推送时间，为空时尚未推送（定时发布的通知到期后由推送调度补推）
+	PushedTime int64 `json:"pushed_time" bson:"pushed_time,omitempty"`
+}
+
+// Pusher 通知的实时推送
+type Pusher interface {
+	// Publish 推送已到发布时间的通知
+	Publish(ctx context.Context, storeID string, n *Model) error
 }
 
 // ResourceName 返回资源名称
@@ -82,3 +120,8 @@ func (m *Model) ResourceName() string {
 func (m *Model) CollectionName() string {
 	return collectionNamePrefix + modelName + collectionNameSuffix
 }
+
+// ArchiveCollectionName 返回归档表名称
+func (m *Model) ArchiveCollectionName() string {
+	return collectionNamePrefix + modelName + archiveSuffix
+}
@@ -0,0 +1,147 @@
+package page
+
+import (
+	"github.com/r2day/m3s/client/app"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Register 登记页面，已登记时更新名称
+func (m *Model) Register(appType app.AppType, packageType, path, name string) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.UpdateOne(m.Context.Context,
+		bson.D{
+			{Key: "app_type", Value: appType},
+			{Key: "package_type", Value: packageType},
+			{Key: "path", Value: Normalize(path)},
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: name}}}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// Unregister 取消登记页面
+func (m *Model) Unregister(appType app.AppType, packageType, path string) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.DeleteOne(m.Context.Context, bson.D{
+		{Key: "app_type", Value: appType},
+		{Key: "package_type", Value: packageType},
+		{Key: "path", Value: Normalize(path)},
+	})
+	return err
+}
+
+// GetByAppType 获取小程序类型登记的页面，packageType 为空时返回所有包
+func (m *Model) GetByAppType(appType app.AppType, packageType string) ([]*Model, error) {
+	filter := bson.D{{Key: "app_type", Value: appType}}
+	if packageType != "" {
+		filter = append(filter, bson.E{Key: "package_type", Value: packageType})
+	}
+	return m.find(filter)
+}
+
+// Load 加载所有登记的页面，返回的集合不允许外部链接，需要时设置其 AllowExternal
+func (m *Model) Load() (*Registry, error) {
+	pages, err := m.find(bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistry(pages), nil
+}
+
+// LoadFor 仅加载校验链接所需的页面：包类型下的登记范围以及链接对应的页面
+func (m *Model) LoadFor(packageTypes []string, urls []string) (*Registry, error) {
+	r := NewRegistry(nil)
+	if len(packageTypes) == 0 {
+		return r, nil
+	}
+	scopes, err := m.scopes(packageTypes)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range scopes {
+		r.add(p)
+	}
+	paths := make([]string, 0, len(urls))
+	for _, url := range urls {
+		if path := Normalize(url); path != "" && !External(url) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return r, nil
+	}
+	pages, err := m.find(bson.D{
+		{Key: "package_type", Value: bson.D{{Key: "$in", Value: packageTypes}}},
+		{Key: "path", Value: bson.D{{Key: "$in", Value: paths}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pages {
+		r.add(p)
+	}
+	return r, nil
+}
+
+// Check 校验链接是否为已登记的页面，规则见 Registry.Check
+func (m *Model) Check(appTypes []app.AppType, packageType, url string) error {
+	r, err := m.LoadFor([]string{packageType}, []string{url})
+	if err != nil {
+		return err
+	}
+	return r.Check(appTypes, packageType, url)
+}
+
+// scopes 包类型下已登记页面的小程序类型
+func (m *Model) scopes(packageTypes []string) ([]*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	cursor, err := coll.Aggregate(m.Context.Context, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "package_type", Value: bson.D{{Key: "$in", Value: packageTypes}}}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: bson.D{
+			{Key: "app_type", Value: "$app_type"},
+			{Key: "package_type", Value: "$package_type"},
+		}}}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "app_type", Value: "$_id.app_type"},
+			{Key: "package_type", Value: "$_id.package_type"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Model, 0)
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (m *Model) find(filter bson.D) ([]*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	results := make([]*Model, 0)
+	cursor, err := coll.Find(m.Context.Context, filter, options.Find().SetSort(bson.D{{Key: "path", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CreateIndexes 创建页面唯一索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateOne(m.Context.Context, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "app_type", Value: 1},
+			{Key: "package_type", Value: 1},
+			{Key: "path", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,49 @@
+package page
+
+import (
+	"github.com/open4go/model"
+	"github.com/r2day/m3s/client/app"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "client_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_config"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "page"
+)
+
+// Model 小程序页面登记
+// 按小程序类型与包类型登记可跳转的页面路径，用于校验通知与主页配置中的链接
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// AppType 小程序类型
+	AppType app.AppType `json:"app_type" bson:"app_type"`
+	// PackageType 小程序包类型：main,private,public
+	PackageType string `json:"package_type" bson:"package_type"`
+	// Path 页面路径（包含分包根目录），例如：pages/index/index
+	Path string `json:"path" bson:"path"`
+	// Name 页面名称
+	Name string `json:"name" bson:"name,omitempty"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
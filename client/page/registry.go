@@ -0,0 +1,109 @@
+package page
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/r2day/m3s/client/app"
+)
+
+var (
+	// ErrPageNotFound 页面未登记
+	ErrPageNotFound = errors.New("page not registered")
+	// ErrExternalURL 不允许外部链接
+	ErrExternalURL = errors.New("external url not allowed")
+	// ErrBrokenLink 配置中存在无效链接
+	ErrBrokenLink = errors.New("broken links")
+)
+
+// key 登记的页面
+type key struct {
+	appType     app.AppType
+	packageType string
+	path        string
+}
+
+// Registry 已登记的页面集合
+type Registry struct {
+	// AllowExternal 是否允许 http(s) 外部链接
+	AllowExternal bool
+	pages         map[key]bool
+	// scopes 已登记页面的小程序类型与包类型
+	scopes map[key]bool
+}
+
+// NewRegistry 由页面列表创建集合
+func NewRegistry(pages []*Model) *Registry {
+	r := &Registry{pages: make(map[key]bool), scopes: make(map[key]bool)}
+	for _, p := range pages {
+		r.add(p)
+	}
+	return r
+}
+
+// add 登记页面，路径为空时只登记小程序类型与包类型
+func (r *Registry) add(p *Model) {
+	if path := Normalize(p.Path); path != "" {
+		r.pages[key{p.AppType, p.PackageType, path}] = true
+	}
+	r.scopes[key{appType: p.AppType, packageType: p.PackageType}] = true
+}
+
+// Normalize 规整页面路径：去掉参数、锚点以及首尾的 /
+func Normalize(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	return strings.Trim(strings.TrimSpace(url), "/")
+}
+
+// External 是否为 http(s) 外部链接
+func External(url string) bool {
+	url = strings.ToLower(strings.TrimSpace(url))
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// Check 校验链接是否为已登记的页面
+// appTypes 为空时只要任一小程序类型登记了该页面即可，否则每个登记了该包类型的小程序类型都需要登记
+// 包类型为空或没有任何小程序类型登记该包类型时不校验，避免在登记页面之前保存的配置无法修改
+func (r *Registry) Check(appTypes []app.AppType, packageType, url string) error {
+	if strings.TrimSpace(url) == "" {
+		return nil
+	}
+	if External(url) {
+		if r.AllowExternal {
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrExternalURL, url)
+	}
+	if packageType == "" {
+		return nil
+	}
+	path := Normalize(url)
+	if len(appTypes) == 0 {
+		known := false
+		for scope := range r.scopes {
+			if scope.packageType != packageType {
+				continue
+			}
+			known = true
+			if r.pages[key{scope.appType, packageType, path}] {
+				return nil
+			}
+		}
+		if !known {
+			return nil
+		}
+		return fmt.Errorf("%w: %s (%s)", ErrPageNotFound, path, packageType)
+	}
+	for _, appType := range appTypes {
+		if !r.scopes[key{appType: appType, packageType: packageType}] {
+			continue
+		}
+		if !r.pages[key{appType, packageType, path}] {
+			return fmt.Errorf("%w: %s (%s, app type %d)", ErrPageNotFound, path, packageType, appType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package page
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/r2day/m3s/client/app"
+)
+
+func TestRegistryCheck(t *testing.T) {
+	r := NewRegistry([]*Model{
+		{AppType: app.WxApp, PackageType: "main", Path: "pages/index/index"},
+		{AppType: app.WxApp, PackageType: "main", Path: "/pages/menu/menu"},
+		{AppType: app.AlipayApp, PackageType: "main", Path: "pages/index/index"},
+		{AppType: app.WxApp, PackageType: "private", Path: "pages/coupon/coupon"},
+	})
+	both := []app.AppType{app.WxApp, app.AlipayApp}
+
+	tests := []struct {
+		name        string
+		appTypes    []app.AppType
+		packageType string
+		url         string
+		want        error
+	}{
+		{"empty url", nil, "", "", nil},
+		{"blank url", nil, "main", "  ", nil},
+		{"registered", nil, "main", "pages/index/index", nil},
+		{"normalized", nil, "main", "/pages/menu/menu?id=1#top", nil},
+		{"not registered", nil, "main", "pages/cart/cart", ErrPageNotFound},
+		{"any app type", nil, "main", "pages/menu/menu", nil},
+		{"every app type", both, "main", "pages/index/index", nil},
+		{"missing for one app type", both, "main", "pages/menu/menu", ErrPageNotFound},
+		{"app type without package skipped", both, "private", "pages/coupon/coupon", nil},
+		{"other package", nil, "private", "pages/index/index", ErrPageNotFound},
+		{"empty package type skipped", nil, "", "pages/index/index", nil},
+		{"unregistered package type skipped", nil, "public", "pages/index/index", nil},
+		{"unregistered for app type skipped", []app.AppType{app.DouYin}, "main", "pages/cart/cart", nil},
+		{"external", nil, "main", "https://example.com", ErrExternalURL},
+		{"external upper case", nil, "main", "HTTP://example.com", ErrExternalURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.Check(tt.appTypes, tt.packageType, tt.url)
+			if tt.want == nil && err != nil {
+				t.Fatalf("Check() = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Check() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryAllowExternal(t *testing.T) {
+	r := NewRegistry(nil)
+	r.AllowExternal = true
+	if err := r.Check(nil, "", "https://example.com/a"); err != nil {
+		t.Fatalf("Check() = %v, want nil", err)
+	}
+	if err := NewRegistry(nil).Check(nil, "main", "https://example.com/a"); !errors.Is(err, ErrExternalURL) {
+		t.Fatalf("Check() = %v, want %v", err, ErrExternalURL)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"pages/index/index":        "pages/index/index",
+		" /pages/index/index/ ":    "pages/index/index",
+		"pages/index/index?id=1":   "pages/index/index",
+		"pages/index/index#anchor": "pages/index/index",
+		"/":                        "",
+	}
+	for in, want := range tests {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Broadcaster 在多个服务实例之间分发事件
+// Publish 发布的事件会通过 Subscribe 交给所有实例（包括自身）的 Hub
+type Broadcaster interface {
+	// Publish 发布事件
+	Publish(ctx context.Context, e *Event) error
+	// Subscribe 接收事件直到 ctx 结束
+	Subscribe(ctx context.Context, handle func(*Event)) error
+}
+
+// LocalBroadcaster 进程内分发，适用于单实例部署
+type LocalBroadcaster struct {
+	mu       sync.RWMutex
+	handlers map[int]func(*Event)
+	next     int
+}
+
+// NewLocalBroadcaster 创建进程内分发
+func NewLocalBroadcaster() *LocalBroadcaster {
+	return &LocalBroadcaster{handlers: make(map[int]func(*Event))}
+}
+
+// Publish 发布事件
+func (b *LocalBroadcaster) Publish(ctx context.Context, e *Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handle := range b.handlers {
+		handle(e)
+	}
+	return nil
+}
+
+// Subscribe 接收事件直到 ctx 结束
+func (b *LocalBroadcaster) Subscribe(ctx context.Context, handle func(*Event)) error {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.handlers[id] = handle
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.handlers, id)
+	b.mu.Unlock()
+	return ctx.Err()
+}
+
+// RedisBroadcaster 通过 Redis（或兼容协议的服务）发布订阅在多个实例之间分发
+type RedisBroadcaster struct {
+	// Client Redis 客户端
+	Client redis.UniversalClient
+	// Channel 发布订阅的频道
+	Channel string
+}
+
+// NewRedisBroadcaster 创建 Redis 分发，channel 为空时使用默认频道
+func NewRedisBroadcaster(client redis.UniversalClient, channel string) *RedisBroadcaster {
+	if channel == "" {
+		channel = defaultChannel
+	}
+	return &RedisBroadcaster{Client: client, Channel: channel}
+}
+
+// Publish 发布事件
+func (b *RedisBroadcaster) Publish(ctx context.Context, e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.Client.Publish(ctx, b.Channel, data).Err()
+}
+
+// Subscribe 接收事件直到 ctx 结束，无法解析的消息会被忽略
+func (b *RedisBroadcaster) Subscribe(ctx context.Context, handle func(*Event)) error {
+	sub := b.Client.Subscribe(ctx, b.Channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			e := &Event{}
+			if err := json.Unmarshal([]byte(msg.Payload), e); err != nil {
+				continue
+			}
+			handle(e)
+		}
+	}
+}
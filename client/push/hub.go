@@ -0,0 +1,181 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/r2day/m3s/client/notification"
+	"github.com/r2day/m3s/message/status"
+)
+
+const (
+	// defaultChannel 默认的发布订阅频道
+	defaultChannel = "client_notification_push"
+	// defaultReplaySize 每个门店保留用于断线重放的事件数量
+	defaultReplaySize = 100
+	// clientBuffer 每个连接的发送缓冲，写满时断开连接由客户端重连补发
+	clientBuffer = 16
+	// eventNotification 新通知事件
+	eventNotification = "notification"
+)
+
+// Event 推送给客户端的事件
+type Event struct {
+	// ID 事件编号，客户端重连时通过 Last-Event-ID 带回
+	ID string `json:"id"`
+	// Name 事件名称
+	Name string `json:"name"`
+	// StoreID 门店
+	StoreID string `json:"store_id"`
+	// Receiver 接收用户，为空时推送给门店所有连接
+	Receiver string `json:"receiver,omitempty"`
+	// Audience 目标受众
+	Audience *notification.Audience `json:"audience,omitempty"`
+	// Data 事件内容
+	Data json.RawMessage `json:"data"`
+}
+
+// visible 判断事件是否推送给该连接
+func (e *Event) visible(c *client) bool {
+	if e.StoreID != c.storeID {
+		return false
+	}
+	if e.Receiver != "" && e.Receiver != c.user.UserID {
+		return false
+	}
+	return e.Audience.Match(c.user)
+}
+
+// client 一个客户端连接
+type client struct {
+	storeID string
+	user    *notification.UserContext
+	events  chan *Event
+	closed  bool
+}
+
+// Hub 按门店与用户将事件分发给已连接的客户端
+type Hub struct {
+	// Broadcaster 多实例之间的事件分发
+	Broadcaster Broadcaster
+	// ReplaySize 每个门店保留用于断线重放的事件数量
+	ReplaySize int
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	history map[string][]*Event
+}
+
+// NewHub 创建分发中心，b 为空时使用进程内分发
+// 需要调用 Run 接收事件
+func NewHub(b Broadcaster) *Hub {
+	if b == nil {
+		b = NewLocalBroadcaster()
+	}
+	return &Hub{
+		Broadcaster: b,
+		ReplaySize:  defaultReplaySize,
+		clients:     make(map[*client]struct{}),
+		history:     make(map[string][]*Event),
+	}
+}
+
+// Run 从 Broadcaster 接收事件并分发，直到 ctx 结束
+func (h *Hub) Run(ctx context.Context) error {
+	return h.Broadcaster.Subscribe(ctx, h.dispatch)
+}
+
+// Publish 推送新发布的通知
+// 已抑制或尚未到发布时间的通知不会推送
+func (h *Hub) Publish(ctx context.Context, storeID string, n *notification.Model) error {
+	if n.Status != status.Sent || n.PublishTime > time.Now().Unix() {
+		return nil
+	}
+	// 受众只用于分发，不发送给客户端
+	data, err := json.Marshal(n.Public())
+	if err != nil {
+		return err
+	}
+	return h.Broadcaster.Publish(ctx, &Event{
+		ID:       n.ID.Hex(),
+		Name:     eventNotification,
+		StoreID:  storeID,
+		Receiver: n.Receiver,
+		Audience: n.Audience,
+		Data:     data,
+	})
+}
+
+// subscribe 注册连接，并返回 lastEventID 之后该连接可见的历史事件
+// lastEventID 不在保留的历史中时返回全部保留的历史事件
+func (h *Hub) subscribe(storeID string, user *notification.UserContext, lastEventID string) (*client, []*Event) {
+	if user == nil {
+		user = &notification.UserContext{}
+	}
+	c := &client{storeID: storeID, user: user, events: make(chan *Event, clientBuffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+	if lastEventID == "" {
+		return c, nil
+	}
+	history := h.history[storeID]
+	start := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ID == lastEventID {
+			start = i + 1
+			break
+		}
+	}
+	replay := make([]*Event, 0)
+	for _, e := range history[start:] {
+		if e.visible(c) {
+			replay = append(replay, e)
+		}
+	}
+	return c, replay
+}
+
+// unsubscribe 注销连接
+func (h *Hub) unsubscribe(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.close(c)
+}
+
+// close 关闭连接的发送通道，需持有锁
+func (h *Hub) close(c *client) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	delete(h.clients, c)
+	close(c.events)
+}
+
+// dispatch 记录事件并发送给可见的连接
+func (h *Hub) dispatch(e *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	history := append(h.history[e.StoreID], e)
+	if h.ReplaySize > 0 && len(history) > h.ReplaySize {
+		history = history[len(history)-h.ReplaySize:]
+	}
+	h.history[e.StoreID] = history
+
+	for c := range h.clients {
+		if !e.visible(c) {
+			continue
+		}
+		select {
+		case c.events <- e:
+		default:
+			// 客户端处理过慢，断开后由其携带 Last-Event-ID 重连补发
+			h.close(c)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package push
+
+import (
+	"context"
+	"time"
+
+	"github.com/open4go/log"
+	"github.com/r2day/m3s/client/notification"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// defaultScheduleInterval 默认检查定时通知的间隔
+	defaultScheduleInterval = 10 * time.Second
+	// defaultMaxDelay 默认推送延迟上限，超过后不再推送
+	defaultMaxDelay = 10 * time.Minute
+	// defaultScheduleBatch 默认每次最多推送的通知数
+	defaultScheduleBatch = 100
+)
+
+// Scheduler 推送定时发布的通知
+// 发布时间在未来的通知在 Publish 时不会推送，到期后由调度领取并推送
+type Scheduler struct {
+	// DB 数据库
+	DB *mongo.Database
+	// Hub 分发中心
+	Hub *Hub
+	// Interval 检查间隔
+	Interval time.Duration
+	// MaxDelay 推送延迟上限，服务停止超过该时长后到期的通知不再推送
+	MaxDelay time.Duration
+	// BatchSize 每次最多推送的通知数
+	BatchSize int
+}
+
+// NewScheduler 创建定时通知推送
+func NewScheduler(db *mongo.Database, hub *Hub) *Scheduler {
+	return &Scheduler{
+		DB:        db,
+		Hub:       hub,
+		Interval:  defaultScheduleInterval,
+		MaxDelay:  defaultMaxDelay,
+		BatchSize: defaultScheduleBatch,
+	}
+}
+
+// Run 定期推送到期的通知直到 ctx 结束
+func (s *Scheduler) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = defaultScheduleInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.PushDue(ctx, time.Now()); err != nil {
+			log.Log(ctx).Error(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// PushDue 推送 now 时刻已到期且尚未推送的通知，返回推送的数量
+func (s *Scheduler) PushDue(ctx context.Context, now time.Time) (int, error) {
+	n := &notification.Model{}
+	n.Init(ctx, s.DB, n.CollectionName())
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultScheduleBatch
+	}
+	maxDelay := s.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+	pushed := 0
+	for {
+		due, err := n.ClaimDue(now, maxDelay, batchSize)
+		if err != nil {
+			return pushed, err
+		}
+		for _, item := range due {
+			if err = s.Hub.Publish(ctx, item.Meta.MerchantID, item); err != nil {
+				log.Log(ctx).WithField("notification", item.ID.Hex()).Error(err)
+				continue
+			}
+			pushed++
+		}
+		if len(due) < batchSize {
+			return pushed, nil
+		}
+	}
+}
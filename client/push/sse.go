@@ -0,0 +1,109 @@
+package push
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/r2day/m3s/client/notification"
+)
+
+const (
+	// defaultHeartbeat 默认心跳间隔
+	defaultHeartbeat = 25 * time.Second
+	// defaultRetry 建议客户端的重连间隔
+	defaultRetry = 3 * time.Second
+)
+
+// Handler 通过 Server-Sent Events 推送通知
+type Handler struct {
+	// Hub 分发中心
+	Hub *Hub
+	// Heartbeat 心跳间隔，防止代理断开空闲连接
+	Heartbeat time.Duration
+	// Retry 建议客户端的重连间隔
+	Retry time.Duration
+	// Resolve 根据登录态解析门店与用户，返回错误时拒绝连接；为空时拒绝所有连接
+	Resolve func(r *http.Request) (string, *notification.UserContext, error)
+}
+
+// NewHandler 创建 SSE 处理器，resolve 需基于登录态解析门店与用户
+func NewHandler(hub *Hub, resolve func(r *http.Request) (string, *notification.UserContext, error)) *Handler {
+	return &Handler{
+		Hub:       hub,
+		Heartbeat: defaultHeartbeat,
+		Retry:     defaultRetry,
+		Resolve:   resolve,
+	}
+}
+
+// ServeHTTP 保持连接并推送事件，直到客户端断开
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	if h.Resolve == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	storeID, user, err := h.Resolve(r)
+	if err != nil || storeID == "" || user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	c, replay := h.Hub.subscribe(storeID, user, lastEventID)
+	defer h.Hub.unsubscribe(c)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if h.Retry > 0 {
+		fmt.Fprintf(w, "retry: %d\n\n", h.Retry.Milliseconds())
+	}
+	for _, e := range replay {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := h.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-c.events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent 按 SSE 格式写入事件，内容为单行 JSON
+func writeEvent(w http.ResponseWriter, e *Event) error {
+	_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Name, e.Data)
+	return err
+}
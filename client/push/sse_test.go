@@ -0,0 +1,106 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/r2day/m3s/client/notification"
+	"github.com/r2day/m3s/message/status"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHandlerRejectsUnauthenticated(t *testing.T) {
+	tests := []struct {
+		name    string
+		resolve func(r *http.Request) (string, *notification.UserContext, error)
+	}{
+		{"no resolver", nil},
+		{"resolver error", func(r *http.Request) (string, *notification.UserContext, error) {
+			return "", nil, errors.New("not logged in")
+		}},
+		{"no store", func(r *http.Request) (string, *notification.UserContext, error) {
+			return "", &notification.UserContext{UserID: "u1"}, nil
+		}},
+	}
+	for _, tt := range tests {
+		h := NewHandler(NewHub(nil), tt.resolve)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?store_id=s1&user_id=u1", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", tt.name, rec.Code)
+		}
+	}
+}
+
+func TestHubPublishAndReplay(t *testing.T) {
+	hub := NewHub(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	time.Sleep(10 * time.Millisecond)
+
+	c, _ := hub.subscribe("s1", &notification.UserContext{UserID: "u1"}, "")
+	now := time.Now().Unix()
+	items := []*notification.Model{
+		{ID: primitive.NewObjectID(), Status: status.Sent, PublishTime: now},
+		{ID: primitive.NewObjectID(), Status: status.Sent, PublishTime: now, Receiver: "u2"},
+		{ID: primitive.NewObjectID(), Status: status.Suppressed, PublishTime: now},
+		{ID: primitive.NewObjectID(), Status: status.Sent, PublishTime: now + 3600},
+		{ID: primitive.NewObjectID(), Status: status.Sent, PublishTime: now, Receiver: "u1"},
+	}
+	for _, n := range items {
+		if err := hub.Publish(ctx, "s1", n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{items[0].ID.Hex(), items[4].ID.Hex()}
+	for _, id := range want {
+		select {
+		case e := <-c.events:
+			if e.ID != id {
+				t.Errorf("event id = %s, want %s", e.ID, id)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+	hub.unsubscribe(c)
+
+	_, replay := hub.subscribe("s1", &notification.UserContext{UserID: "u1"}, items[0].ID.Hex())
+	if len(replay) != 1 || replay[0].ID != items[4].ID.Hex() {
+		t.Errorf("replay = %v, want only %s", replay, items[4].ID.Hex())
+	}
+}
+
+func TestHubPublishHidesAudience(t *testing.T) {
+	hub := NewHub(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	time.Sleep(10 * time.Millisecond)
+
+	c, _ := hub.subscribe("s1", &notification.UserContext{UserID: "u1"}, "")
+	n := &notification.Model{
+		ID:          primitive.NewObjectID(),
+		Status:      status.Sent,
+		PublishTime: time.Now().Unix(),
+		Audience:    &notification.Audience{Users: []string{"u1", "u2"}},
+	}
+	if err := hub.Publish(ctx, "s1", n); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-c.events:
+		if strings.Contains(string(e.Data), "u2") || strings.Contains(string(e.Data), `"audience":{`) {
+			t.Errorf("event data exposes the audience: %s", e.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+}
@@ -0,0 +1,208 @@
+package job
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/open4go/log"
+	"github.com/r2day/m3s/device/printer"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrInvalidSign 回调签名校验失败
+	ErrInvalidSign = errors.New("invalid callback signature")
+	// ErrMissingJobID 回调缺少任务编号
+	ErrMissingJobID = errors.New("callback missing vendor job id")
+)
+
+const (
+	// defaultJobIDField 厂商回调中的任务编号字段
+	defaultJobIDField = "orderId"
+	// defaultStatusField 厂商回调中的状态字段
+	defaultStatusField = "status"
+	// defaultSignField 厂商回调中的签名字段
+	defaultSignField = "sign"
+	// defaultErrorField 厂商回调中的失败原因字段
+	defaultErrorField = "msg"
+)
+
+// Signer 厂商回调签名算法
+type Signer func(params url.Values, key string) string
+
+// FailureListener 打印失败事件监听（例如：通知店员补打）
+type FailureListener func(ctx context.Context, job *Model)
+
+// SHA1Signer 默认签名算法
+// 除签名外的参数按名称排序拼接为 k1=v1&k2=v2，末尾追加 UserKey 后取 SHA1
+func SHA1Signer(params url.Values, key string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == defaultSignField {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params.Get(k))
+	}
+	sum := sha1.Sum([]byte(strings.Join(pairs, "&") + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CallbackHandler 云打印厂商任务结果回调
+// 使用打印机的 UserKey 校验签名，根据厂商任务编号更新打印任务状态
+type CallbackHandler struct {
+	// DB 数据库
+	DB *mongo.Database
+	// Signer 签名算法，为空时使用 SHA1Signer
+	Signer Signer
+	// SuccessStatus 表示打印成功的厂商状态值
+	SuccessStatus []string
+	// JobIDField 任务编号字段
+	JobIDField string
+	// StatusField 状态字段
+	StatusField string
+	// SignField 签名字段
+	SignField string
+	// ErrorField 失败原因字段
+	ErrorField string
+
+	listeners []FailureListener
+}
+
+// NewCallbackHandler 创建回调处理
+func NewCallbackHandler(db *mongo.Database) *CallbackHandler {
+	return &CallbackHandler{
+		DB:            db,
+		Signer:        SHA1Signer,
+		SuccessStatus: []string{"1"},
+		JobIDField:    defaultJobIDField,
+		StatusField:   defaultStatusField,
+		SignField:     defaultSignField,
+		ErrorField:    defaultErrorField,
+	}
+}
+
+// OnFailed 注册打印失败事件监听
+func (h *CallbackHandler) OnFailed(fn FailureListener) {
+	h.listeners = append(h.listeners, fn)
+}
+
+// ServeHTTP 处理厂商 POST 回调
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	_, err := h.Handle(r.Context(), r.PostForm)
+	switch {
+	case err == nil, errors.Is(err, ErrAlreadyFinished):
+		// 重复回调同样返回成功，避免厂商持续重试
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"data": "OK"})
+	case errors.Is(err, ErrInvalidSign):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, ErrMissingJobID):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		log.Log(r.Context()).WithField("params", r.PostForm).Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// Handle 处理回调参数，返回更新后的打印任务
+func (h *CallbackHandler) Handle(ctx context.Context, params url.Values) (*Model, error) {
+	vendorJobID := params.Get(orDefault(h.JobIDField, defaultJobIDField))
+	if vendorJobID == "" {
+		return nil, ErrMissingJobID
+	}
+
+	j := &Model{}
+	j.Init(ctx, h.DB, j.CollectionName())
+	// 任务或打印机不存在时与签名错误返回相同结果，避免泄露任务是否存在
+	current, err := j.GetByVendorJobID(vendorJobID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidSign
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &printer.Model{}
+	p.Init(ctx, h.DB, p.CollectionName())
+	err = p.GetOne(p, current.PrinterID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidSign
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !h.verify(params, p.PrinterConf.UserKey) {
+		return nil, ErrInvalidSign
+	}
+
+	status := params.Get(orDefault(h.StatusField, defaultStatusField))
+	state, reason := Printed, ""
+	if !h.success(status) {
+		state = Failed
+		reason = params.Get(orDefault(h.ErrorField, defaultErrorField))
+	}
+	updated, err := j.Finish(current.ID, state, status, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.State == Failed {
+		for _, fn := range h.listeners {
+			fn(ctx, updated)
+		}
+	}
+	return updated, nil
+}
+
+// verify 校验签名
+func (h *CallbackHandler) verify(params url.Values, key string) bool {
+	signer := h.Signer
+	if signer == nil {
+		signer = SHA1Signer
+	}
+	sign := params.Get(orDefault(h.SignField, defaultSignField))
+	if sign == "" || key == "" {
+		return false
+	}
+	expected := signer(params, key)
+	return hmac.Equal([]byte(strings.ToLower(sign)), []byte(strings.ToLower(expected)))
+}
+
+// success 判断厂商状态是否为打印成功
+func (h *CallbackHandler) success(status string) bool {
+	for _, s := range h.SuccessStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
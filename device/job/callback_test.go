@@ -0,0 +1,105 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSHA1Signer(t *testing.T) {
+	params := url.Values{
+		"status":  {"1"},
+		"orderId": {"o1"},
+		"sign":    {"ignored"},
+	}
+	// sha1("orderId=o1&status=1" + "key")，签名字段不参与计算
+	const want = "cd2fd86f250828b279523b8ab2acece60ac8971a"
+	if got := SHA1Signer(params, "key"); got != want {
+		t.Fatalf("SHA1Signer() = %q, want %q", got, want)
+	}
+	params.Set("sign", "changed")
+	if got := SHA1Signer(params, "key"); got != want {
+		t.Fatalf("SHA1Signer() = %q, want %q", got, want)
+	}
+	if got := SHA1Signer(params, "other"); got == want {
+		t.Fatal("SHA1Signer() ignores the key")
+	}
+}
+
+func TestCallbackVerify(t *testing.T) {
+	params := func(sign string) url.Values {
+		v := url.Values{"orderId": {"o1"}, "status": {"1"}}
+		if sign != "" {
+			v.Set("sign", sign)
+		}
+		return v
+	}
+	valid := SHA1Signer(params(""), "key")
+
+	tests := []struct {
+		name   string
+		h      *CallbackHandler
+		params url.Values
+		key    string
+		want   bool
+	}{
+		{"valid", &CallbackHandler{}, params(valid), "key", true},
+		{"upper case", &CallbackHandler{}, params(strings.ToUpper(valid)), "key", true},
+		{"wrong key", &CallbackHandler{}, params(valid), "other", false},
+		{"missing sign", &CallbackHandler{}, params(""), "key", false},
+		{"empty key", &CallbackHandler{}, params(valid), "", false},
+		{"tampered", &CallbackHandler{}, func() url.Values { v := params(valid); v.Set("status", "2"); return v }(), "key", false},
+		{"custom signer", &CallbackHandler{Signer: func(url.Values, string) string { return "x" }}, params("x"), "key", true},
+		{"custom sign field", &CallbackHandler{SignField: "signature"}, url.Values{"signature": {"x"}}, "key", false},
+	}
+	for _, tt := range tests {
+		if got := tt.h.verify(tt.params, tt.key); got != tt.want {
+			t.Errorf("%s: verify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCallbackSuccess(t *testing.T) {
+	h := NewCallbackHandler(nil)
+	h.SuccessStatus = []string{"1", "success"}
+	tests := map[string]bool{"1": true, "success": true, "0": false, "": false}
+	for status, want := range tests {
+		if got := h.success(status); got != want {
+			t.Errorf("success(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestCallbackServeHTTP(t *testing.T) {
+	h := NewCallbackHandler(nil)
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"method not allowed", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"missing job id", http.MethodPost, "status=1&sign=x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/callback", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestCallbackHandleMissingJobID(t *testing.T) {
+	h := &CallbackHandler{JobIDField: "job"}
+	_, err := h.Handle(context.Background(), url.Values{"orderId": {"o1"}})
+	if !errors.Is(err, ErrMissingJobID) {
+		t.Fatalf("Handle() = %v, want %v", err, ErrMissingJobID)
+	}
+}
@@ -0,0 +1,89 @@
+package job
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrAlreadyFinished 任务已经是最终状态（重复回调）
+var ErrAlreadyFinished = errors.New("print job already finished")
+
+// GetByVendorJobID 根据厂商任务编号获取打印任务
+func (m *Model) GetByVendorJobID(vendorJobID string) (*Model, error) {
+	result := &Model{}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{{Key: "vendor_job_id", Value: vendorJobID}}
+	if err := coll.FindOne(m.Context.Context, filter).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetByOrderID 获取订单的打印任务
+func (m *Model) GetByOrderID(orderID string) ([]*Model, error) {
+
+	results := make([]*Model, 0)
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{{Key: "order_id", Value: orderID}}
+
+	// 获取数据列表
+	cursor, err := coll.Find(m.Context.Context, filter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// MarkSubmitted 记录提交到厂商后返回的任务编号
+func (m *Model) MarkSubmitted(id string, vendorJobID string) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	_, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: objID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "vendor_job_id", Value: vendorJobID},
+			{Key: "state", Value: Submitted},
+			{Key: "submitted_time", Value: time.Now().Unix()},
+		}}})
+	return err
+}
+
+// Finish 更新任务的最终状态
+// 仅在任务尚未结束时更新，重复回调返回 ErrAlreadyFinished
+func (m *Model) Finish(id primitive.ObjectID, state State, vendorStatus string, reason string) (*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "state", Value: bson.D{{Key: "$in", Value: bson.A{Pending, Submitted}}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "state", Value: state},
+		{Key: "vendor_status", Value: vendorStatus},
+		{Key: "error", Value: reason},
+		{Key: "finished_time", Value: time.Now().Unix()},
+	}}}
+
+	result := &Model{}
+	err := coll.FindOneAndUpdate(m.Context.Context, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAlreadyFinished
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
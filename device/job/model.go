@@ -0,0 +1,82 @@
+package job
+
+import (
+	"github.com/open4go/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "device_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_flow"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "print_job"
+)
+
+// State 打印任务状态
+type State int
+
+const (
+	// Pending 待提交
+	Pending State = iota
+	// Submitted 已提交到云打印厂商
+	Submitted
+	// Printed 打印完成
+	Printed
+	// Failed 打印失败
+	Failed
+)
+
+// Final 是否为最终状态（回调只会更新一次）
+func (s State) Final() bool {
+	return s == Printed || s == Failed
+}
+
+// Model 打印任务
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// PrinterID 打印机
+	PrinterID string `json:"printer_id" bson:"printer_id,omitempty"`
+	// TemplateID 模版
+	TemplateID string `json:"template_id" bson:"template_id,omitempty"`
+	// OrderID 订单
+	OrderID string `json:"order_id" bson:"order_id,omitempty"`
+	// Station 出单工位
+	Station string `json:"station" bson:"station,omitempty"`
+	// Copies 打印份数
+	Copies int `json:"copies" bson:"copies,omitempty"`
+	// Content 打印内容
+	Content string `json:"content" bson:"content,omitempty"`
+	// VendorJobID 云打印厂商返回的任务编号
+	VendorJobID string `json:"vendor_job_id" bson:"vendor_job_id,omitempty"`
+	// State 任务状态
+	State State `json:"state" bson:"state"`
+	// VendorStatus 厂商回调的原始状态
+	VendorStatus string `json:"vendor_status" bson:"vendor_status,omitempty"`
+	// Error 失败原因
+	Error string `json:"error" bson:"error,omitempty"`
+	// SubmittedTime 提交时间
+	SubmittedTime int64 `json:"submitted_time" bson:"submitted_time,omitempty"`
+	// FinishedTime 完成时间（回调时间）
+	FinishedTime int64 `json:"finished_time" bson:"finished_time,omitempty"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
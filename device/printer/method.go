@@ -29,3 +29,33 @@ func (m *Model) GetByStoreID(id string) ([]*Model, error) {
 	}
 	return results, nil
 }
+
+// GetByStoreIDAndKind 获取门店下指定类型的打印机
+func (m *Model) GetByStoreIDAndKind(id string, kind DeviceKind) ([]*Model, error) {
+
+	results := make([]*Model, 0)
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.D{{Key: "meta.merchant_id", Value: objID}}
+	if kind == Receipt {
+		// 历史数据未设置kind字段，默认为小票打印机
+		filter = append(filter, bson.E{Key: "kind", Value: bson.D{{Key: "$in", Value: bson.A{nil, Receipt}}}})
+	} else {
+		filter = append(filter, bson.E{Key: "kind", Value: kind})
+	}
+
+	// 获取数据列表
+	cursor, err := coll.Find(m.Context.Context, filter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
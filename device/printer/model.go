@@ -17,6 +17,47 @@ const (
 	modelName = "printer"
 )
 
+// DeviceKind 打印设备类型
+type DeviceKind int
+
+const (
+	// Receipt 小票打印机（飞鹅等云打印标记语言）
+	Receipt DeviceKind = iota
+	// Label 标签打印机（TSPL指令，例如杯贴）
+	Label
+)
+
+// PaperWidth 纸张宽度（单位mm）
+type PaperWidth int
+
+const (
+	// Paper58 58mm 小票纸
+	Paper58 PaperWidth = 58
+	// Paper80 80mm 小票纸
+	Paper80 PaperWidth = 80
+)
+
+const (
+	// defaultPaper 未设置纸张宽度时默认为58mm
+	defaultPaper = Paper58
+	// columns58 58mm纸张每行字符列数
+	columns58 = 32
+	// columns80 80mm纸张每行字符列数
+	columns80 = 48
+)
+
+// Columns 返回该纸张宽度每行可打印的字符列数
+func (w PaperWidth) Columns() int {
+	switch w {
+	case Paper80:
+		return columns80
+	case Paper58:
+		return columns58
+	default:
+		return defaultPaper.Columns()
+	}
+}
+
 // Model 打印机
 type Model struct {
 	// 模型继承
@@ -29,8 +70,17 @@ type Model struct {
 	Name string `json:"name" bson:"name,omitempty"`
 	// 类型
 	Type string `json:"type" bson:"type,omitempty"`
+	// Kind 设备类型：小票/标签
+	Kind DeviceKind `json:"kind" bson:"kind,omitempty"`
 	// 商户配置
 	PrinterConf Printer `json:"printer_conf" bson:"printer_conf,omitempty"`
+	// PaperWidth 纸张宽度（单位mm: 58, 80）
+	PaperWidth PaperWidth `json:"paper_width" bson:"paper_width,omitempty"`
+	// Columns 每行可打印的字符列数（半角字符计1列，全角中文计2列）
+	// 为空时根据纸张宽度推算
+	Columns int `json:"columns" bson:"columns,omitempty"`
+	// Routes 出单规则（后厨/吧台/收银等工位），为空时打印整单一份
+	Routes []*RouteRule `json:"routes" bson:"routes,omitempty"`
 	// 打印机实时状态
 	// 在线查询api接口，因此不存储到数据库
 	Status bool `json:"status" bson:"-"`
@@ -43,6 +93,15 @@ type Printer struct {
 	Debug   string `json:"debug"  bson:"debug"`
 }
 
+// CharColumns 返回打印机每行可打印的字符列数
+// 优先使用手动配置的列数，否则根据纸张宽度推算
+func (m *Model) CharColumns() int {
+	if m.Columns > 0 {
+		return m.Columns
+	}
+	return m.PaperWidth.Columns()
+}
+
 // ResourceName 返回资源名称
 func (m *Model) ResourceName() string {
 	//m.Meta = m.GetMeta()
@@ -0,0 +1,228 @@
+package printer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open4go/req5rsp/req"
+)
+
+// Station 出单工位
+type Station string
+
+const (
+	// KitchenStation 后厨
+	KitchenStation Station = "kitchen"
+	// BarStation 吧台
+	BarStation Station = "bar"
+	// ReceiptStation 收银小票
+	ReceiptStation Station = "receipt"
+)
+
+// Channel 订单渠道，与下单请求中的取餐模式一致
+type Channel string
+
+const (
+	// DineIn 堂食
+	DineIn Channel = "in-store"
+	// Pickup 打包自提
+	Pickup Channel = "pack"
+	// Delivery 外卖
+	Delivery Channel = "take-out"
+)
+
+// RouteRule 出单规则
+// 各条件为空时表示不限制，多个条件同时满足时规则生效
+type RouteRule struct {
+	// Name 规则名称
+	Name string `json:"name" bson:"name,omitempty"`
+	// Station 出单工位
+	Station Station `json:"station" bson:"station,omitempty"`
+	// Disabled 是否停用
+	Disabled bool `json:"disabled" bson:"disabled,omitempty"`
+	// Categories 商品分类，只打印属于这些分类的商品
+	Categories []string `json:"categories" bson:"categories,omitempty"`
+	// Channels 订单渠道：堂食/自提/外卖
+	Channels []Channel `json:"channels" bson:"channels,omitempty"`
+	// TimeWindows 生效时段
+	TimeWindows []*TimeWindow `json:"time_windows" bson:"time_windows,omitempty"`
+	// TemplateID 使用的模版，为空时使用门店默认模版
+	TemplateID string `json:"template_id" bson:"template_id,omitempty"`
+	// Copies 打印份数，为空时打印一份
+	Copies int `json:"copies" bson:"copies,omitempty"`
+}
+
+// TimeWindow 生效时段
+// 结束时间早于开始时间表示跨天，例如 22:00-02:00
+type TimeWindow struct {
+	// Start 开始时间 HH:MM
+	Start string `json:"start" bson:"start,omitempty"`
+	// End 结束时间 HH:MM
+	End string `json:"end" bson:"end,omitempty"`
+	// Weekdays 生效的星期（0 表示周日），为空时每天生效
+	Weekdays []time.Weekday `json:"weekdays" bson:"weekdays,omitempty"`
+}
+
+// Assignment 出单结果：哪台打印机用哪个模版打印哪些商品几份
+type Assignment struct {
+	// Printer 打印机
+	Printer *Model `json:"printer"`
+	// Station 出单工位
+	Station Station `json:"station"`
+	// Rule 命中的规则名称
+	Rule string `json:"rule"`
+	// TemplateID 使用的模版，为空时使用门店默认模版
+	TemplateID string `json:"template_id"`
+	// Copies 打印份数
+	Copies int `json:"copies"`
+	// Items 需要打印的商品
+	Items []req.OrderItem `json:"items"`
+}
+
+// RouteOrder 获取门店打印机并计算订单的出单结果
+func (m *Model) RouteOrder(storeID string, order *req.PlaceOrder, now time.Time) ([]*Assignment, error) {
+	printers, err := m.GetByStoreID(storeID)
+	if err != nil {
+		return nil, err
+	}
+	return Route(printers, order, now), nil
+}
+
+// Route 根据打印机的出单规则计算订单需要打印的内容
+// 未配置规则的打印机作为收银小票打印整单一份
+func Route(printers []*Model, order *req.PlaceOrder, now time.Time) []*Assignment {
+	results := make([]*Assignment, 0)
+	for _, p := range printers {
+		if len(p.Routes) == 0 {
+			results = append(results, &Assignment{
+				Printer: p,
+				Station: ReceiptStation,
+				Copies:  1,
+				Items:   order.Items,
+			})
+			continue
+		}
+		for _, r := range p.Routes {
+			if !r.Match(order, now) {
+				continue
+			}
+			items := r.FilterItems(order.Items)
+			if len(items) == 0 {
+				continue
+			}
+			copies := r.Copies
+			if copies <= 0 {
+				copies = 1
+			}
+			results = append(results, &Assignment{
+				Printer:    p,
+				Station:    r.Station,
+				Rule:       r.Name,
+				TemplateID: r.TemplateID,
+				Copies:     copies,
+				Items:      items,
+			})
+		}
+	}
+	return results
+}
+
+// Match 判断订单渠道与下单时间是否满足规则
+func (r *RouteRule) Match(order *req.PlaceOrder, now time.Time) bool {
+	if r.Disabled {
+		return false
+	}
+	if len(r.Channels) > 0 && !containsChannel(r.Channels, Channel(order.PickupMode)) {
+		return false
+	}
+	if len(r.TimeWindows) == 0 {
+		return true
+	}
+	for _, w := range r.TimeWindows {
+		if w.Contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterItems 过滤出规则分类下的商品
+func (r *RouteRule) FilterItems(items []req.OrderItem) []req.OrderItem {
+	if len(r.Categories) == 0 {
+		return items
+	}
+	results := make([]req.OrderItem, 0, len(items))
+	for _, item := range items {
+		for _, c := range r.Categories {
+			if item.CateID == c {
+				results = append(results, item)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// Contains 判断时间是否在时段内
+// 时间格式错误的时段视为不生效
+func (w *TimeWindow) Contains(t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if end <= start && minute < end {
+		// 跨天时段的后半段属于前一天的规则
+		day = (day + 6) % 7
+	}
+	if len(w.Weekdays) > 0 && !containsWeekday(w.Weekdays, day) {
+		return false
+	}
+	if start < end {
+		return minute >= start && minute < end
+	}
+	// 跨天，例如 22:00-02:00；开始与结束相同表示全天
+	return minute >= start || minute < end
+}
+
+// parseClock 解析 HH:MM 为当天的分钟数
+func parseClock(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+	h, err := strconv.Atoi(parts[0])
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+	return h*60 + m, nil
+}
+
+func containsChannel(list []Channel, c Channel) bool {
+	for _, v := range list {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWeekday(list []time.Weekday, d time.Weekday) bool {
+	for _, v := range list {
+		if v == d {
+			return true
+		}
+	}
+	return false
+}
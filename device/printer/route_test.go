@@ -0,0 +1,69 @@
+package printer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open4go/req5rsp/req"
+)
+
+func TestTimeWindowContains(t *testing.T) {
+	// 2024-01-01 是周一
+	at := func(day int, clock string) time.Time {
+		c, _ := time.Parse("15:04", clock)
+		return time.Date(2024, 1, day, c.Hour(), c.Minute(), 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		w    TimeWindow
+		t    time.Time
+		want bool
+	}{
+		{"inside", TimeWindow{Start: "10:00", End: "14:00"}, at(1, "12:00"), true},
+		{"end exclusive", TimeWindow{Start: "10:00", End: "14:00"}, at(1, "14:00"), false},
+		{"before", TimeWindow{Start: "10:00", End: "14:00"}, at(1, "09:59"), false},
+		{"overnight evening", TimeWindow{Start: "22:00", End: "02:00"}, at(1, "23:00"), true},
+		{"overnight morning", TimeWindow{Start: "22:00", End: "02:00"}, at(2, "01:00"), true},
+		{"overnight outside", TimeWindow{Start: "22:00", End: "02:00"}, at(2, "03:00"), false},
+		{"all day", TimeWindow{Start: "00:00", End: "00:00"}, at(1, "05:00"), true},
+		{"weekday match", TimeWindow{Start: "10:00", End: "14:00", Weekdays: []time.Weekday{time.Monday}}, at(1, "12:00"), true},
+		{"weekday mismatch", TimeWindow{Start: "10:00", End: "14:00", Weekdays: []time.Weekday{time.Tuesday}}, at(1, "12:00"), false},
+		// 周一 22:00 开始的跨天时段在周二凌晨仍属于周一
+		{"overnight weekday", TimeWindow{Start: "22:00", End: "02:00", Weekdays: []time.Weekday{time.Monday}}, at(2, "01:00"), true},
+		{"invalid clock", TimeWindow{Start: "25:00", End: "02:00"}, at(1, "12:00"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.w.Contains(tt.t); got != tt.want {
+			t.Errorf("%s: Contains = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRoute(t *testing.T) {
+	order := &req.PlaceOrder{
+		PickupMode: string(DineIn),
+		Items: []req.OrderItem{
+			{CateID: "tea", Name: "奶茶", Number: 1},
+			{CateID: "coffee", Name: "拿铁", Number: 1},
+			{CateID: "snack", Name: "蛋糕", Number: 1},
+		},
+	}
+	cashier := &Model{Name: "cashier"}
+	kitchen := &Model{Name: "kitchen", Routes: []*RouteRule{
+		{Name: "drinks", Station: BarStation, Categories: []string{"tea", "coffee"}, Copies: 2},
+		{Name: "delivery only", Station: KitchenStation, Channels: []Channel{Delivery}},
+		{Name: "disabled", Station: KitchenStation, Disabled: true},
+		{Name: "no items", Station: KitchenStation, Categories: []string{"noodle"}},
+	}}
+
+	got := Route([]*Model{cashier, kitchen}, order, time.Now())
+	if len(got) != 2 {
+		t.Fatalf("assignments = %d, want 2", len(got))
+	}
+	if got[0].Printer != cashier || got[0].Station != ReceiptStation || got[0].Copies != 1 || len(got[0].Items) != 3 {
+		t.Errorf("printer without rules should print the whole order once: %+v", got[0])
+	}
+	if got[1].Rule != "drinks" || got[1].Station != BarStation || got[1].Copies != 2 || len(got[1].Items) != 2 {
+		t.Errorf("unexpected drinks assignment: %+v", got[1])
+	}
+}
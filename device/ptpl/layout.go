@@ -0,0 +1,188 @@
+package ptpl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/r2day/m3s/device/printer"
+)
+
+const (
+	// qtyWidth 商品行中数量列宽度（例如: x99）
+	qtyWidth = 4
+	// priceWidth 商品行中价格列宽度（例如: 1234.00）
+	priceWidth = 10
+	// minNameWidth 商品名称列最小宽度
+	minNameWidth = 8
+)
+
+// Layout 小票排版
+// 按打印机每行字符列数计算对齐、换行，半角字符计1列，全角中文计2列
+type Layout struct {
+	// Columns 每行字符列数
+	Columns int
+}
+
+// NewLayout 创建指定列数的排版
+func NewLayout(columns int) *Layout {
+	if columns <= 0 {
+		columns = printer.Paper58.Columns()
+	}
+	return &Layout{Columns: columns}
+}
+
+// LayoutFor 根据打印机配置创建排版
+func LayoutFor(p *printer.Model) *Layout {
+	return NewLayout(p.CharColumns())
+}
+
+// RuneWidth 返回字符在小票上占用的列数
+func RuneWidth(r rune) int {
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return 0
+	case unicode.IsControl(r) || unicode.Is(unicode.Mn, r):
+		return 0
+	case isWide(r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// isWide 是否为全角字符（中日韩文字及全角符号）
+func isWide(r rune) bool {
+	if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hangul, r) ||
+		unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) {
+		return true
+	}
+	switch {
+	case r >= 0x1100 && r <= 0x115F: // 韩文字母
+		return true
+	case r >= 0x2E80 && r <= 0x303E: // 中日韩部首及标点
+		return true
+	case r >= 0x3041 && r <= 0x33FF: // 假名及中日韩兼容字符
+		return true
+	case r >= 0xFE30 && r <= 0xFE4F: // 中日韩兼容形式
+		return true
+	case r >= 0xFF00 && r <= 0xFF60: // 全角字符
+		return true
+	case r >= 0xFFE0 && r <= 0xFFE6: // 全角符号
+		return true
+	}
+	return false
+}
+
+// StringWidth 返回字符串在小票上占用的列数
+func StringWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += RuneWidth(r)
+	}
+	return w
+}
+
+// Wrap 将字符串按指定列宽折行
+// 不会把全角字符拆开，单个超宽字符独占一行
+func Wrap(s string, width int) []string {
+	if width <= 0 {
+		return []string{s}
+	}
+	lines := make([]string, 0)
+	for _, para := range strings.Split(s, "\n") {
+		var b strings.Builder
+		w := 0
+		for _, r := range para {
+			rw := RuneWidth(r)
+			if w+rw > width && w > 0 {
+				lines = append(lines, b.String())
+				b.Reset()
+				w = 0
+			}
+			b.WriteRune(r)
+			w += rw
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+// padRight 右侧补空格至指定列宽
+func padRight(s string, width int) string {
+	if gap := width - StringWidth(s); gap > 0 {
+		return s + strings.Repeat(" ", gap)
+	}
+	return s
+}
+
+// padLeft 左侧补空格至指定列宽（右对齐）
+func padLeft(s string, width int) string {
+	if gap := width - StringWidth(s); gap > 0 {
+		return strings.Repeat(" ", gap) + s
+	}
+	return s
+}
+
+// Center 居中
+func (l *Layout) Center(s string) string {
+	lines := Wrap(s, l.Columns)
+	for i, line := range lines {
+		gap := l.Columns - StringWidth(line)
+		lines[i] = strings.Repeat(" ", gap/2) + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Right 右对齐
+func (l *Layout) Right(s string) string {
+	lines := Wrap(s, l.Columns)
+	for i, line := range lines {
+		lines[i] = padLeft(line, l.Columns)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Pair 左右两端对齐，例如: "合计          ¥28.00"
+// 左侧内容过长时折行，右侧内容放在最后一行
+func (l *Layout) Pair(left, right string) string {
+	rw := StringWidth(right)
+	if rw >= l.Columns {
+		return strings.Join(append(Wrap(left, l.Columns), l.Right(right)), "\n")
+	}
+	lines := Wrap(left, l.Columns)
+	last := lines[len(lines)-1]
+	if StringWidth(last)+1+rw > l.Columns {
+		lines = append(lines, padLeft(right, l.Columns))
+	} else {
+		lines[len(lines)-1] = padRight(last, l.Columns-rw) + right
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Item 商品行: 名称 数量 价格
+// 名称过长时在名称列内折行，数量与价格只出现在第一行并右对齐
+func (l *Layout) Item(name string, qty int, price string) string {
+	nameWidth := l.Columns - qtyWidth - priceWidth
+	if nameWidth < minNameWidth {
+		// 列数过少时名称独占一行
+		return l.Pair(name, fmt.Sprintf("x%d %s", qty, price))
+	}
+	lines := Wrap(name, nameWidth)
+	lines[0] = padRight(lines[0], nameWidth) +
+		padLeft(fmt.Sprintf("x%d", qty), qtyWidth) +
+		padLeft(price, priceWidth)
+	return strings.Join(lines, "\n")
+}
+
+// Divider 分割线
+func (l *Layout) Divider(ch string) string {
+	if ch == "" {
+		ch = "-"
+	}
+	w := StringWidth(ch)
+	if w == 0 {
+		return ""
+	}
+	return strings.Repeat(ch, l.Columns/w)
+}
@@ -0,0 +1,146 @@
+package ptpl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/r2day/m3s/device/printer"
+)
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"珍珠奶茶", 8},
+		{"奶茶 x2", 7},
+		{"（大杯）", 8},
+		{"a\tb\n", 2},
+	}
+	for _, tt := range tests {
+		if got := StringWidth(tt.s); got != tt.want {
+			t.Errorf("StringWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  []string
+	}{
+		{"abcdef", 4, []string{"abcd", "ef"}},
+		{"珍珠奶茶", 5, []string{"珍珠", "奶茶"}},
+		{"a珍珠", 2, []string{"a", "珍", "珠"}},
+		{"ab\ncd", 10, []string{"ab", "cd"}},
+		{"abc", 0, []string{"abc"}},
+	}
+	for _, tt := range tests {
+		got := Wrap(tt.s, tt.width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Wrap(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutLinesFitColumns(t *testing.T) {
+	for _, columns := range []int{printer.Paper58.Columns(), printer.Paper80.Columns()} {
+		l := NewLayout(columns)
+		outputs := []string{
+			l.Center("欢迎光临"),
+			l.Right("¥28.00"),
+			l.Pair("合计", "28.00"),
+			l.Pair("一个非常非常非常非常非常长的备注信息", "28.00"),
+			l.Item("芝士葡萄（大杯，少冰，七分糖，加珍珠）", 2, "36.00"),
+			l.Divider("-"),
+		}
+		for _, out := range outputs {
+			for _, line := range strings.Split(out, "\n") {
+				if w := StringWidth(line); w > columns {
+					t.Errorf("columns %d: line %q has width %d", columns, line, w)
+				}
+			}
+		}
+	}
+}
+
+func TestLayoutAlignment(t *testing.T) {
+	l := NewLayout(32)
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"center", l.Center("小票"), strings.Repeat(" ", 14) + "小票"},
+		{"right", l.Right("28.00"), strings.Repeat(" ", 27) + "28.00"},
+		{"pair", l.Pair("合计", "28.00"), "合计" + strings.Repeat(" ", 23) + "28.00"},
+		{"divider", l.Divider("="), strings.Repeat("=", 32)},
+		{"wide divider", l.Divider("－"), strings.Repeat("－", 16)},
+		{"item", l.Item("奶茶", 2, "12.00"), "奶茶" + strings.Repeat(" ", 14) + "  x2" + "     12.00"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLayoutItemWrapsName(t *testing.T) {
+	l := NewLayout(32)
+	lines := strings.Split(l.Item("芝士葡萄（大杯，少冰）", 1, "18.00"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q, want 2 lines", lines)
+	}
+	if !strings.HasSuffix(lines[0], "18.00") {
+		t.Errorf("price should be on the first line: %q", lines[0])
+	}
+	if strings.Contains(lines[1], "18.00") {
+		t.Errorf("price should not repeat: %q", lines[1])
+	}
+}
+
+func TestNewLayoutDefaultsTo58mm(t *testing.T) {
+	if got := NewLayout(0).Columns; got != printer.Paper58.Columns() {
+		t.Errorf("NewLayout(0).Columns = %d, want %d", got, printer.Paper58.Columns())
+	}
+}
+
+func TestRender(t *testing.T) {
+	m := &Model{Name: "receipt", Template: `{{center .Title}}
+{{divider "-"}}
+{{range .Items}}{{item .Name .Number (price .Price)}}
+{{end}}{{pair "合计" (price .Total)}}`}
+	data := map[string]interface{}{
+		"Title": "小票",
+		"Items": []map[string]interface{}{{"Name": "奶茶", "Number": 2, "Price": 12.0}},
+		"Total": 24.0,
+	}
+	for _, columns := range []int{32, 48} {
+		out, err := m.Render(columns, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(out, "\n")
+		if len(lines) != 4 {
+			t.Fatalf("columns %d: lines = %q", columns, lines)
+		}
+		for _, line := range lines[1:] {
+			if StringWidth(line) != columns {
+				t.Errorf("columns %d: line %q has width %d", columns, line, StringWidth(line))
+			}
+		}
+		if !strings.HasSuffix(lines[3], "24.00") {
+			t.Errorf("columns %d: total line %q", columns, lines[3])
+		}
+	}
+}
+
+func TestRenderInvalidTemplate(t *testing.T) {
+	m := &Model{Name: "bad", Template: "{{center"}
+	if _, err := m.Render(32, nil); err == nil {
+		t.Error("expected parse error")
+	}
+}
@@ -2,9 +2,22 @@ package ptpl
 
 import (
 	"errors"
+	"time"
+
+	"github.com/open4go/log"
+	"github.com/open4go/model"
+	"github.com/r2day/m3s/device/printer"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrNotPublished 模版尚未发布
+	ErrNotPublished = errors.New("template has not been published")
+	// ErrNoDefault 门店没有可用的默认模版
+	ErrNoDefault = errors.New("no default template found")
 )
 
 func (m *Model) GetByStoreID(id string) ([]*Model, error) {
@@ -29,3 +42,208 @@ func (m *Model) GetByStoreID(id string) ([]*Model, error) {
 	}
 	return results, nil
 }
+
+// Publish 将模版当前草稿发布为新版本
+func (m *Model) Publish(id string) (*Revision, error) {
+	current := &Model{}
+	if err := m.GetOne(current, id); err != nil {
+		return nil, err
+	}
+	return m.publish(current, current.Template, current.Label, 0)
+}
+
+// Rollback 回滚到指定版本
+// 以该版本的内容发布一个新版本，保证发布历史只追加不修改
+func (m *Model) Rollback(id string, version int) (*Revision, error) {
+	current := &Model{}
+	if err := m.GetOne(current, id); err != nil {
+		return nil, err
+	}
+	rev, err := m.GetRevision(id, version)
+	if err != nil {
+		return nil, err
+	}
+	return m.publish(current, rev.Template, rev.Label, version)
+}
+
+// publish 递增版本号并保存快照，同时将草稿内容同步为发布内容
+// 快照保存失败时回滚版本号与发布内容，避免模版指向不存在的版本
+func (m *Model) publish(current *Model, tpl string, label *LabelTemplate, rollbackFrom int) (*Revision, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	now := time.Now().Unix()
+
+	// 原子递增版本号，避免并发发布产生重复版本
+	previous := &Model{}
+	err := coll.FindOneAndUpdate(m.Context.Context,
+		bson.D{{Key: "_id", Value: current.ID}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+			{Key: "$set", Value: bson.D{
+				{Key: "template", Value: tpl},
+				{Key: "label", Value: label},
+				{Key: "published_time", Value: now},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(previous)
+	if err != nil {
+		return nil, err
+	}
+
+	rev := &Revision{
+		TemplateID:    current.ID,
+		Version:       previous.Version + 1,
+		Kind:          current.Kind,
+		Template:      tpl,
+		Label:         label,
+		RollbackFrom:  rollbackFrom,
+		Publisher:     model.GetValueFromCtx(m.Context.Context, model.OperatorKey),
+		PublishedTime: now,
+	}
+	rev.Meta = current.Meta
+	result, err := m.Context.Handler.Collection(rev.CollectionName()).InsertOne(m.Context.Context, rev)
+	if err != nil {
+		if rollbackErr := m.unpublish(previous, rev.Version); rollbackErr != nil {
+			log.Log(m.Context.Context).WithField("template_id", current.ID.Hex()).Error(rollbackErr)
+		}
+		return nil, err
+	}
+	rev.ID = result.InsertedID.(primitive.ObjectID)
+	return rev, nil
+}
+
+// unpublish 撤销未能保存快照的发布，仅当版本号仍为 version（期间没有新的发布）时恢复
+func (m *Model) unpublish(previous *Model, version int) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: previous.ID}, {Key: "version", Value: version}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "version", Value: previous.Version},
+			{Key: "template", Value: previous.Template},
+			{Key: "label", Value: previous.Label},
+			{Key: "published_time", Value: previous.PublishedTime},
+		}}})
+	return err
+}
+
+// GetRevisions 获取模版的发布历史（按版本倒序）
+func (m *Model) GetRevisions(id string) ([]*Revision, error) {
+	results := make([]*Revision, 0)
+	coll := m.Context.Handler.Collection((&Revision{}).CollectionName())
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.D{{Key: "template_id", Value: objID}}
+	opt := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+
+	cursor, err := coll.Find(m.Context.Context, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetRevision 获取模版的指定版本
+func (m *Model) GetRevision(id string, version int) (*Revision, error) {
+	result := &Revision{}
+	coll := m.Context.Handler.Collection(result.CollectionName())
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.D{{Key: "template_id", Value: objID}, {Key: "version", Value: version}}
+	if err := coll.FindOne(m.Context.Context, filter).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetDefault 设置为门店该类型打印机的默认模版
+// 同门店同类型的其他模版会被取消默认
+func (m *Model) SetDefault(id string) error {
+	current := &Model{}
+	if err := m.GetOne(current, id); err != nil {
+		return err
+	}
+	if current.Version == 0 {
+		return ErrNotPublished
+	}
+
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	storeID, _ := primitive.ObjectIDFromHex(current.Meta.MerchantID)
+	_, err := coll.UpdateMany(m.Context.Context,
+		bson.D{
+			{Key: "meta.merchant_id", Value: storeID},
+			{Key: "_id", Value: bson.D{{Key: "$ne", Value: current.ID}}},
+			kindFilter(current.Kind),
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "is_default", Value: false}}}})
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: current.ID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "is_default", Value: true}}}})
+	return err
+}
+
+// ResolveDefault 获取门店指定类型打印机的默认模版
+// 返回的模版内容为已发布版本，而非编辑中的草稿
+// 未设置默认模版时使用最近发布的模版
+func (m *Model) ResolveDefault(storeID string, kind printer.DeviceKind) (*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	objID, _ := primitive.ObjectIDFromHex(storeID)
+	filter := bson.D{
+		{Key: "meta.merchant_id", Value: objID},
+		{Key: "version", Value: bson.D{{Key: "$gt", Value: 0}}},
+		kindFilter(kind),
+	}
+	opt := options.FindOne().SetSort(bson.D{
+		{Key: "is_default", Value: -1},
+		{Key: "published_time", Value: -1},
+	})
+
+	result := &Model{}
+	err := coll.FindOne(m.Context.Context, filter, opt).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNoDefault
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.published(result)
+}
+
+// ResolveFor 获取打印机所属门店的默认模版
+func (m *Model) ResolveFor(p *printer.Model) (*Model, error) {
+	return m.ResolveDefault(p.Meta.MerchantID, p.Kind)
+}
+
+// Published 获取模版的已发布版本
+func (m *Model) Published(id string) (*Model, error) {
+	current := &Model{}
+	if err := m.GetOne(current, id); err != nil {
+		return nil, err
+	}
+	return m.published(current)
+}
+
+// published 使用已发布版本的快照替换草稿内容
+func (m *Model) published(current *Model) (*Model, error) {
+	if current.Version == 0 {
+		return nil, ErrNotPublished
+	}
+	rev, err := m.GetRevision(current.ID.Hex(), current.Version)
+	if err != nil {
+		return nil, err
+	}
+	current.Template = rev.Template
+	current.Label = rev.Label
+	return current, nil
+}
+
+// kindFilter 模版类型过滤条件（历史数据未设置kind字段，视为小票模版）
+func kindFilter(kind printer.DeviceKind) bson.E {
+	if kind == printer.Receipt {
+		return bson.E{Key: "kind", Value: bson.D{{Key: "$in", Value: bson.A{nil, printer.Receipt}}}}
+	}
+	return bson.E{Key: "kind", Value: kind}
+}
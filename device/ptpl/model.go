@@ -2,6 +2,7 @@ package ptpl
 
 import (
 	"github.com/open4go/model"
+	"github.com/r2day/m3s/device/printer"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -13,6 +14,8 @@ const (
 	// CollectionNameSuffix 后缀
 	// 例如, _log, _config, _flow,
 	collectionNameSuffix = "_template"
+	// revisionCollectionSuffix 模版发布历史表后缀
+	revisionCollectionSuffix = "_template_revision"
 	// 这个需要用户根据具体业务完成设定
 	modelName = "printer"
 )
@@ -29,8 +32,122 @@ type Model struct {
 	Name string `json:"name" bson:"name,omitempty"`
 	// 类型
 	Type string `json:"type" bson:"type,omitempty"`
+	// Kind 模版类型：小票/标签，需与打印机类型一致
+	Kind printer.DeviceKind `json:"kind" bson:"kind,omitempty"`
 	// 商户配置
 	Template string `json:"template" bson:"template,omitempty"`
+	// Label 标签模版（仅Kind为标签时有效）
+	Label *LabelTemplate `json:"label,omitempty" bson:"label,omitempty"`
+	// Version 当前已发布的版本号（0 表示尚未发布）
+	// Template/Label 为编辑中的草稿，打印时使用已发布版本的内容
+	Version int `json:"version" bson:"version,omitempty"`
+	// PublishedTime 最近发布时间
+	PublishedTime int64 `json:"published_time" bson:"published_time,omitempty"`
+	// IsDefault 是否为门店该类型打印机的默认模版
+	IsDefault bool `json:"is_default" bson:"is_default,omitempty"`
+}
+
+// Revision 模版发布历史
+// 每次发布/回滚都会保存一份完整的模版快照
+type Revision struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	// TemplateID 所属模版
+	TemplateID primitive.ObjectID `json:"template_id" bson:"template_id"`
+	// Version 版本号
+	Version int `json:"version" bson:"version"`
+	// Kind 模版类型
+	Kind printer.DeviceKind `json:"kind" bson:"kind,omitempty"`
+	// Template 小票模版内容
+	Template string `json:"template" bson:"template,omitempty"`
+	// Label 标签模版内容
+	Label *LabelTemplate `json:"label,omitempty" bson:"label,omitempty"`
+	// RollbackFrom 由哪个版本回滚而来（0 表示正常发布）
+	RollbackFrom int `json:"rollback_from" bson:"rollback_from,omitempty"`
+	// Publisher 发布人
+	Publisher string `json:"publisher" bson:"publisher,omitempty"`
+	// PublishedTime 发布时间
+	PublishedTime int64 `json:"published_time" bson:"published_time"`
+}
+
+// LabelTemplate 标签模版（TSPL）
+// 尺寸单位为mm，元素坐标单位为dot（203dpi 下 1mm = 8 dots）
+type LabelTemplate struct {
+	// Width 标签宽度（mm）
+	Width float64 `json:"width" bson:"width,omitempty"`
+	// Height 标签高度（mm）
+	Height float64 `json:"height" bson:"height,omitempty"`
+	// Gap 标签间隙（mm）
+	Gap float64 `json:"gap" bson:"gap,omitempty"`
+	// GapOffset 间隙偏移（mm）
+	GapOffset float64 `json:"gap_offset" bson:"gap_offset,omitempty"`
+	// Direction 出纸方向：0 或 1
+	Direction int `json:"direction" bson:"direction,omitempty"`
+	// Density 打印浓度 0-15，为空时使用打印机默认值
+	Density int `json:"density" bson:"density,omitempty"`
+	// Texts 文本块
+	Texts []*LabelText `json:"texts" bson:"texts,omitempty"`
+	// Barcodes 一维条码
+	Barcodes []*LabelBarcode `json:"barcodes" bson:"barcodes,omitempty"`
+	// QRCodes 二维码
+	QRCodes []*LabelQRCode `json:"qr_codes" bson:"qr_codes,omitempty"`
+}
+
+// LabelText 标签文本块
+type LabelText struct {
+	// X 横坐标（dot）
+	X int `json:"x" bson:"x"`
+	// Y 纵坐标（dot）
+	Y int `json:"y" bson:"y"`
+	// Font 字体名称，例如: TSS24.BF2（简体中文24x24）
+	Font string `json:"font" bson:"font,omitempty"`
+	// Rotation 旋转角度：0, 90, 180, 270
+	Rotation int `json:"rotation" bson:"rotation,omitempty"`
+	// XMul 横向放大倍数
+	XMul int `json:"x_mul" bson:"x_mul,omitempty"`
+	// YMul 纵向放大倍数
+	YMul int `json:"y_mul" bson:"y_mul,omitempty"`
+	// Content 文本内容（支持模版语法，例如: {{.Name}}）
+	Content string `json:"content" bson:"content,omitempty"`
+}
+
+// LabelBarcode 标签一维条码
+type LabelBarcode struct {
+	// X 横坐标（dot）
+	X int `json:"x" bson:"x"`
+	// Y 纵坐标（dot）
+	Y int `json:"y" bson:"y"`
+	// Type 条码类型，例如: 128, 39, EAN13
+	Type string `json:"type" bson:"type,omitempty"`
+	// Height 条码高度（dot）
+	Height int `json:"height" bson:"height,omitempty"`
+	// HumanReadable 是否打印可读文字
+	HumanReadable bool `json:"human_readable" bson:"human_readable,omitempty"`
+	// Rotation 旋转角度：0, 90, 180, 270
+	Rotation int `json:"rotation" bson:"rotation,omitempty"`
+	// Narrow 窄条宽度（dot）
+	Narrow int `json:"narrow" bson:"narrow,omitempty"`
+	// Wide 宽条宽度（dot）
+	Wide int `json:"wide" bson:"wide,omitempty"`
+	// Content 条码内容（支持模版语法）
+	Content string `json:"content" bson:"content,omitempty"`
+}
+
+// LabelQRCode 标签二维码
+type LabelQRCode struct {
+	// X 横坐标（dot）
+	X int `json:"x" bson:"x"`
+	// Y 纵坐标（dot）
+	Y int `json:"y" bson:"y"`
+	// ECCLevel 纠错等级：L, M, Q, H
+	ECCLevel string `json:"ecc_level" bson:"ecc_level,omitempty"`
+	// CellWidth 单元宽度（dot）
+	CellWidth int `json:"cell_width" bson:"cell_width,omitempty"`
+	// Rotation 旋转角度：0, 90, 180, 270
+	Rotation int `json:"rotation" bson:"rotation,omitempty"`
+	// Content 二维码内容（支持模版语法）
+	Content string `json:"content" bson:"content,omitempty"`
 }
 
 // ResourceName 返回资源名称
@@ -43,3 +160,8 @@ func (m *Model) ResourceName() string {
 func (m *Model) CollectionName() string {
 	return collectionNamePrefix + modelName + collectionNameSuffix
 }
+
+// CollectionName 返回表名称
+func (r *Revision) CollectionName() string {
+	return collectionNamePrefix + modelName + revisionCollectionSuffix
+}
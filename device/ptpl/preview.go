@@ -0,0 +1,218 @@
+package ptpl
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/r2day/m3s/device/printer"
+)
+
+const (
+	// dotsPerMM 标签预览按 203dpi 换算（1mm = 8 dots）
+	dotsPerMM = 8
+)
+
+// markupTag 飞鹅等云打印机的标记语言标签，例如: <CB>标题</CB>、<BR>
+var markupTag = regexp.MustCompile(`<(/?)([A-Z]+)>`)
+
+// htmlTags 标记语言与 HTML 的近似对应关系
+var htmlTags = map[string][2]string{
+	"C":     {`<div style="text-align:center">`, `</div>`},
+	"CB":    {`<div style="text-align:center;font-size:2em;font-weight:bold">`, `</div>`},
+	"B":     {`<span style="font-size:2em">`, `</span>`},
+	"L":     {`<span style="display:inline-block;transform:scaleY(2)">`, `</span>`},
+	"W":     {`<span style="display:inline-block;transform:scaleX(2)">`, `</span>`},
+	"BOLD":  {`<b>`, `</b>`},
+	"RIGHT": {`<div style="text-align:right">`, `</div>`},
+	"QR":    {`<div style="border:1px dashed;text-align:center">[二维码] `, `</div>`},
+	"BR":    {"\n", ""},
+	"CUT":   {`<hr>`, ""},
+	"LOGO":  {`<div style="text-align:center">[LOGO]</div>`, ""},
+}
+
+// textTags 标记语言转换为纯文本时需要保留的内容，未列出的标签直接去除
+var textTags = map[string][2]string{
+	"QR":   {"[二维码] ", ""},
+	"BR":   {"\n", ""},
+	"CUT":  {"\n", ""},
+	"LOGO": {"[LOGO]\n", ""},
+}
+
+// Preview 模版预览结果
+type Preview struct {
+	// Text 纯文本预览（标签模版为TSPL指令）
+	Text string `json:"text"`
+	// HTML HTML 近似预览
+	HTML string `json:"html"`
+}
+
+// OrderData 打印数据
+type OrderData struct {
+	// StoreName 门店名称
+	StoreName string `json:"store_name"`
+	// OrderNo 订单号
+	OrderNo string `json:"order_no"`
+	// PickupCode 取餐号
+	PickupCode string `json:"pickup_code"`
+	// PickupMode 取餐模式：take-out外卖、in-store堂食、pack：打包自提
+	PickupMode string `json:"pickup_mode"`
+	// TableID 餐桌
+	TableID string `json:"table_id"`
+	// Items 商品列表
+	Items []*OrderItem `json:"items"`
+	// Total 商品总额
+	Total float64 `json:"total"`
+	// Discount 优惠金额
+	Discount float64 `json:"discount"`
+	// PayAmount 实付金额
+	PayAmount float64 `json:"pay_amount"`
+	// Remark 备注
+	Remark string `json:"remark"`
+	// Phone 联系电话
+	Phone string `json:"phone"`
+	// Address 配送地址
+	Address string `json:"address"`
+	// CreatedAt 下单时间
+	CreatedAt string `json:"created_at"`
+	// Item 当前打印的商品（标签按杯打印时使用）
+	Item *OrderItem `json:"item"`
+	// Index 当前商品序号，从1开始（例如: 1/3）
+	Index int `json:"index"`
+	// Count 商品总杯数
+	Count int `json:"count"`
+}
+
+// OrderItem 打印商品
+type OrderItem struct {
+	// CateID 商品分类
+	CateID string `json:"cate_id"`
+	// Name 商品名称
+	Name string `json:"name"`
+	// PropsText 规格属性（例如: 大杯/少冰/半糖）
+	PropsText string `json:"props_text"`
+	// Number 数量
+	Number int `json:"number"`
+	// Price 单价
+	Price float64 `json:"price"`
+}
+
+// SampleOrder 预览使用的示例订单
+func SampleOrder() *OrderData {
+	items := []*OrderItem{
+		{CateID: "tea", Name: "招牌芝士葡萄冰沙（大杯）", PropsText: "大杯/少冰/半糖", Number: 2, Price: 28},
+		{CateID: "coffee", Name: "生椰拿铁", PropsText: "热/标准糖", Number: 1, Price: 19.5},
+		{CateID: "snack", Name: "Cheese Cake", Number: 1, Price: 16},
+	}
+	return &OrderData{
+		StoreName:  "示例门店",
+		OrderNo:    "202401010001",
+		PickupCode: "A001",
+		PickupMode: "in-store",
+		TableID:    "8",
+		Items:      items,
+		Total:      91.5,
+		Discount:   5,
+		PayAmount:  86.5,
+		Remark:     "请尽快出餐",
+		Phone:      "13800000000",
+		CreatedAt:  "2024-01-01 12:00:00",
+		Item:       items[0],
+		Index:      1,
+		Count:      4,
+	}
+}
+
+// Preview 使用数据渲染模版预览
+// data 为空时使用示例订单，columns 为小票每行字符列数（标签模版忽略）
+func (m *Model) Preview(columns int, data interface{}) (*Preview, error) {
+	if data == nil {
+		data = SampleOrder()
+	}
+	if m.Kind == printer.Label {
+		return m.previewLabel(data)
+	}
+	out, err := m.Render(columns, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Preview{
+		Text: markupToText(out),
+		HTML: fmt.Sprintf(`<pre style="width:%dch;font-family:monospace;white-space:pre-wrap">%s</pre>`,
+			NewLayout(columns).Columns, markupToHTML(out)),
+	}, nil
+}
+
+// previewLabel 标签模版预览，按 dot 坐标绝对定位
+func (m *Model) previewLabel(data interface{}) (*Preview, error) {
+	cmd, err := m.RenderTSPL(data, 1)
+	if err != nil {
+		return nil, err
+	}
+	l := m.Label
+	var b strings.Builder
+	fmt.Fprintf(&b, `<div style="position:relative;width:%dpx;height:%dpx;border:1px solid;font-family:monospace">`,
+		int(l.Width*dotsPerMM), int(l.Height*dotsPerMM))
+	for _, t := range l.Texts {
+		content, err := renderField(t.Content, data)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, `<div style="position:absolute;left:%dpx;top:%dpx;font-size:%dpx;transform:rotate(%ddeg);transform-origin:0 0">%s</div>`,
+			t.X, t.Y, 24*atLeastOne(t.YMul), t.Rotation, html.EscapeString(content))
+	}
+	for _, c := range l.Barcodes {
+		content, err := renderField(c.Content, data)
+		if err != nil {
+			return nil, err
+		}
+		height := c.Height
+		if height <= 0 {
+			height = defaultBarcodeHeight
+		}
+		fmt.Fprintf(&b, `<div style="position:absolute;left:%dpx;top:%dpx;height:%dpx;border:1px dashed;transform:rotate(%ddeg);transform-origin:0 0">[条码] %s</div>`,
+			c.X, c.Y, height, c.Rotation, html.EscapeString(content))
+	}
+	for _, q := range l.QRCodes {
+		content, err := renderField(q.Content, data)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, `<div style="position:absolute;left:%dpx;top:%dpx;border:1px dashed;transform:rotate(%ddeg);transform-origin:0 0">[二维码] %s</div>`,
+			q.X, q.Y, q.Rotation, html.EscapeString(content))
+	}
+	b.WriteString(`</div>`)
+	return &Preview{Text: cmd, HTML: b.String()}, nil
+}
+
+// markupToText 去除打印标记，转换为纯文本
+func markupToText(s string) string {
+	return replaceMarkup(s, textTags, func(text string) string { return text })
+}
+
+// markupToHTML 打印标记转换为近似的 HTML
+func markupToHTML(s string) string {
+	return replaceMarkup(s, htmlTags, html.EscapeString)
+}
+
+// replaceMarkup 按对应关系替换标记标签，标签之间的文本经 escape 处理
+func replaceMarkup(s string, tags map[string][2]string, escape func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range markupTag.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(escape(s[last:loc[0]]))
+		last = loc[1]
+		closing := loc[3] > loc[2]
+		name := s[loc[4]:loc[5]]
+		if pair, ok := tags[name]; ok {
+			if closing {
+				b.WriteString(pair[1])
+			} else {
+				b.WriteString(pair[0])
+			}
+		}
+	}
+	b.WriteString(escape(s[last:]))
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package ptpl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/r2day/m3s/device/printer"
+)
+
+func TestReplaceMarkup(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantText string
+		wantHTML string
+	}{
+		{"<BOLD>a&b</BOLD>", "a&b", "<b>a&amp;b</b>"},
+		{"x<BR>y", "x\ny", "x\ny"},
+		{"<QR>url</QR>", "[二维码] url", `<div style="border:1px dashed;text-align:center">[二维码] url</div>`},
+		{"<UNKNOWN>z</UNKNOWN>", "z", "z"},
+		{"<script>", "<script>", "&lt;script&gt;"},
+	}
+	for _, tt := range tests {
+		if got := markupToText(tt.in); got != tt.wantText {
+			t.Errorf("markupToText(%q) = %q, want %q", tt.in, got, tt.wantText)
+		}
+		if got := markupToHTML(tt.in); got != tt.wantHTML {
+			t.Errorf("markupToHTML(%q) = %q, want %q", tt.in, got, tt.wantHTML)
+		}
+	}
+}
+
+func TestPreviewReceiptWithSampleOrder(t *testing.T) {
+	m := &Model{Name: "receipt", Template: `<CB>{{.StoreName}}</CB><BR>{{range .Items}}{{item .Name .Number (price .Price)}}<BR>{{end}}{{pair "实付" (price .PayAmount)}}`}
+	p, err := m.Preview(32, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p.Text, "示例门店\n") {
+		t.Errorf("text should start with store name: %q", p.Text)
+	}
+	if !strings.Contains(p.Text, "86.50") {
+		t.Errorf("text missing pay amount: %q", p.Text)
+	}
+	if strings.Contains(p.Text, "<CB>") {
+		t.Errorf("text should not contain markup: %q", p.Text)
+	}
+	if !strings.Contains(p.HTML, "width:32ch") || !strings.Contains(p.HTML, "font-weight:bold") {
+		t.Errorf("unexpected html: %s", p.HTML)
+	}
+}
+
+func TestPreviewLabel(t *testing.T) {
+	m := &Model{Kind: printer.Label, Label: &LabelTemplate{
+		Width:  40,
+		Height: 30,
+		Texts:  []*LabelText{{X: 8, Y: 8, Content: "{{.Item.Name}}<>"}},
+	}}
+	p, err := m.Preview(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p.Text, "SIZE 40 mm,30 mm") {
+		t.Errorf("label text preview should be TSPL: %q", p.Text)
+	}
+	if !strings.Contains(p.HTML, "width:320px;height:240px") {
+		t.Errorf("label size not converted to dots: %s", p.HTML)
+	}
+	if !strings.Contains(p.HTML, "招牌芝士葡萄冰沙（大杯）&lt;&gt;") {
+		t.Errorf("label content not rendered or escaped: %s", p.HTML)
+	}
+}
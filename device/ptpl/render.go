@@ -0,0 +1,42 @@
+package ptpl
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// FuncMap 模版中可使用的排版函数
+//
+//	{{center "小票标题"}}
+//	{{divider "-"}}
+//	{{range .Items}}{{item .Name .Number (price .Price)}}
+//	{{end}}{{pair "合计" (price .Total)}}
+func (l *Layout) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"center":  l.Center,
+		"right":   l.Right,
+		"pair":    l.Pair,
+		"item":    l.Item,
+		"divider": l.Divider,
+		"wrap": func(s string) string {
+			return strings.Join(Wrap(s, l.Columns), "\n")
+		},
+		"price": func(v float64) string {
+			return fmt.Sprintf("%.2f", v)
+		},
+	}
+}
+
+// Render 按指定列数使用数据渲染模版
+func (m *Model) Render(columns int, data interface{}) (string, error) {
+	tpl, err := template.New(m.Name).Funcs(NewLayout(columns).FuncMap()).Parse(m.Template)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err = tpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
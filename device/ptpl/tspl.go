@@ -0,0 +1,152 @@
+package ptpl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/r2day/m3s/device/printer"
+)
+
+const (
+	// tsplEOL TSPL指令换行符
+	tsplEOL = "\r\n"
+	// defaultLabelFont 默认简体中文字体
+	defaultLabelFont = "TSS24.BF2"
+	// defaultBarcodeType 默认条码类型
+	defaultBarcodeType = "128"
+	// defaultBarcodeHeight 默认条码高度（dot）
+	defaultBarcodeHeight = 48
+	// defaultECCLevel 默认二维码纠错等级
+	defaultECCLevel = "M"
+	// defaultCellWidth 默认二维码单元宽度（dot）
+	defaultCellWidth = 4
+)
+
+var (
+	// ErrKindMismatch 模版类型与打印机类型不一致
+	ErrKindMismatch = errors.New("template kind does not match printer kind")
+	// ErrNoLabel 标签模版缺少标签配置
+	ErrNoLabel = errors.New("label template is empty")
+)
+
+// RenderTSPL 使用数据渲染标签模版，输出TSPL指令
+// copies 为打印份数（杯贴一般一杯一张）
+func (m *Model) RenderTSPL(data interface{}, copies int) (string, error) {
+	if m.Label == nil {
+		return "", ErrNoLabel
+	}
+	if copies <= 0 {
+		copies = 1
+	}
+	l := m.Label
+	var b strings.Builder
+	writeCmd(&b, "SIZE %s mm,%s mm", mm(l.Width), mm(l.Height))
+	writeCmd(&b, "GAP %s mm,%s mm", mm(l.Gap), mm(l.GapOffset))
+	writeCmd(&b, "DIRECTION %d", l.Direction)
+	if l.Density > 0 {
+		writeCmd(&b, "DENSITY %d", l.Density)
+	}
+	writeCmd(&b, "CLS")
+
+	for _, t := range l.Texts {
+		content, err := renderField(t.Content, data)
+		if err != nil {
+			return "", err
+		}
+		writeCmd(&b, "TEXT %d,%d,%s,%d,%d,%d,%s",
+			t.X, t.Y, quote(orDefault(t.Font, defaultLabelFont)), t.Rotation,
+			atLeastOne(t.XMul), atLeastOne(t.YMul), quote(content))
+	}
+	for _, c := range l.Barcodes {
+		content, err := renderField(c.Content, data)
+		if err != nil {
+			return "", err
+		}
+		height := c.Height
+		if height <= 0 {
+			height = defaultBarcodeHeight
+		}
+		readable := 0
+		if c.HumanReadable {
+			readable = 1
+		}
+		writeCmd(&b, "BARCODE %d,%d,%s,%d,%d,%d,%d,%d,%s",
+			c.X, c.Y, quote(orDefault(c.Type, defaultBarcodeType)), height, readable,
+			c.Rotation, atLeastOne(c.Narrow), atLeastOne(c.Wide), quote(content))
+	}
+	for _, q := range l.QRCodes {
+		content, err := renderField(q.Content, data)
+		if err != nil {
+			return "", err
+		}
+		cell := q.CellWidth
+		if cell <= 0 {
+			cell = defaultCellWidth
+		}
+		writeCmd(&b, "QRCODE %d,%d,%s,%d,A,%d,%s",
+			q.X, q.Y, orDefault(q.ECCLevel, defaultECCLevel), cell, q.Rotation, quote(content))
+	}
+	writeCmd(&b, "PRINT %d,1", copies)
+	return b.String(), nil
+}
+
+// RenderFor 按打印机类型渲染模版
+// 小票打印机按纸张宽度排版，标签打印机输出TSPL指令
+func (m *Model) RenderFor(p *printer.Model, data interface{}, copies int) (string, error) {
+	if m.Kind != p.Kind {
+		return "", ErrKindMismatch
+	}
+	if m.Kind == printer.Label {
+		return m.RenderTSPL(data, copies)
+	}
+	return m.Render(p.CharColumns(), data)
+}
+
+// renderField 渲染标签元素中的模版内容
+func renderField(content string, data interface{}) (string, error) {
+	if !strings.Contains(content, "{{") {
+		return content, nil
+	}
+	tpl, err := template.New("field").Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err = tpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// writeCmd 写入一条TSPL指令
+func writeCmd(b *strings.Builder, format string, args ...interface{}) {
+	fmt.Fprintf(b, format, args...)
+	b.WriteString(tsplEOL)
+}
+
+// quote TSPL字符串转义，双引号需写作 \["]
+func quote(s string) string {
+	s = strings.NewReplacer("\r", "", "\n", " ", `"`, `\["]`).Replace(s)
+	return `"` + s + `"`
+}
+
+// mm 格式化毫米数值，去掉多余的小数位
+func mm(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", v), "0"), ".")
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func atLeastOne(v int) int {
+	if v <= 0 {
+		return 1
+	}
+	return v
+}
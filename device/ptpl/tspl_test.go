@@ -0,0 +1,108 @@
+package ptpl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/r2day/m3s/device/printer"
+)
+
+func cupLabel() *Model {
+	return &Model{
+		Kind: printer.Label,
+		Label: &LabelTemplate{
+			Width:   40,
+			Height:  30,
+			Gap:     2,
+			Density: 8,
+			Texts: []*LabelText{
+				{X: 16, Y: 16, Content: "{{.Name}}"},
+				{X: 16, Y: 56, Font: "3", XMul: 2, YMul: 2, Content: `say "hi"`},
+			},
+			Barcodes: []*LabelBarcode{{X: 16, Y: 120, HumanReadable: true, Content: "{{.No}}"}},
+			QRCodes:  []*LabelQRCode{{X: 200, Y: 16, ECCLevel: "H", Content: "https://m3s/{{.No}}"}},
+		},
+	}
+}
+
+func TestRenderTSPL(t *testing.T) {
+	out, err := cupLabel().RenderTSPL(map[string]string{"Name": "芝士葡萄", "No": "A012"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"SIZE 40 mm,30 mm",
+		"GAP 2 mm,0 mm",
+		"DIRECTION 0",
+		"DENSITY 8",
+		"CLS",
+		`TEXT 16,16,"TSS24.BF2",0,1,1,"芝士葡萄"`,
+		`TEXT 16,56,"3",0,2,2,"say \["]hi\["]"`,
+		`BARCODE 16,120,"128",48,1,0,1,1,"A012"`,
+		`QRCODE 200,16,H,4,A,0,"https://m3s/A012"`,
+		"PRINT 2,1",
+		"",
+	}
+	if got := strings.Split(out, "\r\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("RenderTSPL =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRenderTSPLDefaults(t *testing.T) {
+	m := &Model{Kind: printer.Label, Label: &LabelTemplate{Width: 50.5, Height: 25}}
+	out, err := m.RenderTSPL(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"SIZE 50.5 mm,25 mm\r\n", "PRINT 1,1\r\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "DENSITY") {
+		t.Errorf("density should be omitted when unset:\n%s", out)
+	}
+}
+
+func TestRenderTSPLNewlinesInContent(t *testing.T) {
+	m := &Model{Kind: printer.Label, Label: &LabelTemplate{Texts: []*LabelText{{Content: "a\r\nb"}}}}
+	out, err := m.RenderTSPL(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"a b"`) {
+		t.Errorf("newlines should not break the command:\n%s", out)
+	}
+}
+
+func TestRenderFor(t *testing.T) {
+	label := cupLabel()
+	receipt := &Model{Kind: printer.Receipt, Template: `{{divider "-"}}`}
+	labelPrinter := &printer.Model{Kind: printer.Label}
+	receiptPrinter := &printer.Model{Kind: printer.Receipt, PaperWidth: printer.Paper80}
+
+	tests := []struct {
+		name    string
+		tpl     *Model
+		p       *printer.Model
+		prefix  string
+		wantErr error
+	}{
+		{"label", label, labelPrinter, "SIZE ", nil},
+		{"receipt", receipt, receiptPrinter, strings.Repeat("-", 48), nil},
+		{"label on receipt printer", label, receiptPrinter, "", ErrKindMismatch},
+		{"receipt on label printer", receipt, labelPrinter, "", ErrKindMismatch},
+		{"empty label", &Model{Kind: printer.Label}, labelPrinter, "", ErrNoLabel},
+	}
+	for _, tt := range tests {
+		out, err := tt.tpl.RenderFor(tt.p, map[string]string{"Name": "n", "No": "1"}, 1)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !strings.HasPrefix(out, tt.prefix) {
+			t.Errorf("%s: output %q, want prefix %q", tt.name, out, tt.prefix)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package alipay
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultGateway 支付宝开放平台网关
+	defaultGateway = "https://openapi.alipay.com/gateway.do"
+	// defaultTimeout 接口调用超时时间
+	defaultTimeout = 10 * time.Second
+	// templateMessageMethod 小程序模版消息发送接口
+	templateMessageMethod = "alipay.open.app.mini.templatemessage.send"
+)
+
+// userIDPattern 支付宝用户号（2088开头16位数字），否则视为 open_id
+var userIDPattern = regexp.MustCompile(`^2088\d{12}$`)
+
+// shanghai 支付宝接口要求的时间时区
+var shanghai = time.FixedZone("CST", 8*3600)
+
+// Client 支付宝小程序服务端接口
+type Client struct {
+	// AppID 小程序 appid
+	AppID string
+	// PrivateKey 应用私钥（RSA2）
+	PrivateKey *rsa.PrivateKey
+	// Gateway 网关地址，为空时使用正式地址（测试时可指向本地服务）
+	Gateway string
+	// HTTPClient 为空时使用默认超时的 http.Client
+	HTTPClient *http.Client
+}
+
+// NewClient 创建支付宝小程序接口客户端
+// privateKey 支持 PEM 或不带头尾的 base64（PKCS1/PKCS8）
+func NewClient(appID, privateKey string) (*Client, error) {
+	key, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{AppID: appID, PrivateKey: key}, nil
+}
+
+// ParsePrivateKey 解析应用私钥
+func ParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	var der []byte
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		der = block.Bytes
+	} else {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		der = b
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("alipay private key is not RSA")
+	}
+	return key, nil
+}
+
+// DataItem 模版关键词的值
+type DataItem struct {
+	Value string `json:"value"`
+}
+
+// TemplateMessage 小程序模版（订阅）消息
+type TemplateMessage struct {
+	// ToUser 接收者支付宝用户号或 open_id
+	ToUser string
+	// TemplateID 模版编号
+	TemplateID string
+	// Page 点击消息跳转的页面
+	Page string
+	// Data 模版内容
+	Data map[string]DataItem
+}
+
+// SendTemplateMessage 发送小程序模版消息
+func (c *Client) SendTemplateMessage(ctx context.Context, msg *TemplateMessage) error {
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		return err
+	}
+	biz := map[string]string{
+		"user_template_id": msg.TemplateID,
+		"page":             msg.Page,
+		"data":             string(data),
+	}
+	if userIDPattern.MatchString(msg.ToUser) {
+		biz["to_user_id"] = msg.ToUser
+	} else {
+		biz["to_open_id"] = msg.ToUser
+	}
+	content, err := json.Marshal(biz)
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, templateMessageMethod, string(content))
+}
+
+// call 调用网关接口，返回业务错误
+func (c *Client) call(ctx context.Context, method, bizContent string) error {
+	params := url.Values{}
+	params.Set("app_id", c.AppID)
+	params.Set("method", method)
+	params.Set("format", "JSON")
+	params.Set("charset", "utf-8")
+	params.Set("sign_type", "RSA2")
+	params.Set("timestamp", time.Now().In(shanghai).Format("2006-01-02 15:04:05"))
+	params.Set("version", "1.0")
+	params.Set("biz_content", bizContent)
+	sign, err := c.sign(params)
+	if err != nil {
+		return err
+	}
+	params.Set("sign", sign)
+
+	gateway := c.Gateway
+	if gateway == "" {
+		gateway = defaultGateway
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gateway, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: defaultTimeout}
+	}
+	rsp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("alipay http status %d", rsp.StatusCode)
+	}
+
+	// 返回内容的键为接口名称将 . 替换为 _ 并追加 _response
+	body := map[string]json.RawMessage{}
+	if err = json.NewDecoder(rsp.Body).Decode(&body); err != nil {
+		return err
+	}
+	key := strings.ReplaceAll(method, ".", "_") + "_response"
+	raw, ok := body[key]
+	if !ok {
+		raw, ok = body["error_response"]
+	}
+	if !ok {
+		return fmt.Errorf("alipay response missing %s", key)
+	}
+	result := &Error{}
+	if err = json.Unmarshal(raw, result); err != nil {
+		return err
+	}
+	if result.Code != codeSuccess {
+		return result
+	}
+	return nil
+}
+
+// sign 按参数名排序拼接后使用 SHA256WithRSA 签名
+func (c *Client) sign(params url.Values) (string, error) {
+	if c.PrivateKey == nil {
+		return "", errors.New("alipay private key not set")
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == "sign" || params.Get(k) == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params.Get(k))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(pairs, "&")))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.PrivateKey, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
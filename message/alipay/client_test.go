@@ -0,0 +1,171 @@
+package alipay
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key := testKey(t)
+	pkcs1 := x509.MarshalPKCS1PrivateKey(key)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   string
+		err  bool
+	}{
+		{"pkcs1 pem", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: pkcs1})), false},
+		{"pkcs8 pem", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})), false},
+		{"pkcs1 base64", base64.StdEncoding.EncodeToString(pkcs1), false},
+		{"pkcs8 base64", " " + base64.StdEncoding.EncodeToString(pkcs8) + "\n", false},
+		{"invalid base64", "not a key", true},
+		{"invalid der", base64.StdEncoding.EncodeToString([]byte("abc")), true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrivateKey(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: ParsePrivateKey succeeded", tt.name)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(key) {
+			t.Errorf("%s: ParsePrivateKey = %v, want the generated key", tt.name, err)
+		}
+	}
+}
+
+func TestErrorTemporary(t *testing.T) {
+	tests := []struct {
+		err  Error
+		want bool
+	}{
+		{Error{Code: codeUnavailable}, true},
+		{Error{Code: "40004", SubCode: "isp.unknow-error"}, true},
+		{Error{Code: "40004", SubCode: "isv.invalid-template"}, false},
+		{Error{Code: "40002", SubCode: "isv.invalid-signature"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Temporary(); got != tt.want {
+			t.Errorf("%s/%s: Temporary = %v, want %v", tt.err.Code, tt.err.SubCode, got, tt.want)
+		}
+	}
+}
+
+// verifySign 按网关规则使用公钥校验请求签名
+func verifySign(pub *rsa.PublicKey, params url.Values) error {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "sign" && params.Get(k) != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params.Get(k))
+	}
+	sig, err := base64.StdEncoding.DecodeString(params.Get("sign"))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(strings.Join(pairs, "&")))
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig)
+}
+
+func TestSendTemplateMessage(t *testing.T) {
+	key := testKey(t)
+	tests := []struct {
+		name     string
+		toUser   string
+		userKey  string
+		response string
+		wantErr  *Error
+	}{
+		{"user id", "2088000000000001", "to_user_id",
+			`{"alipay_open_app_mini_templatemessage_send_response":{"code":"10000","msg":"Success"}}`, nil},
+		{"open id", "074a1CcTG1LelxKe4xQC0zgNdId0nxi95b5lsNpazWYoCo5", "to_open_id",
+			`{"alipay_open_app_mini_templatemessage_send_response":{"code":"10000","msg":"Success"}}`, nil},
+		{"business error", "2088000000000001", "to_user_id",
+			`{"alipay_open_app_mini_templatemessage_send_response":{"code":"40004","msg":"Business Failed","sub_code":"isv.invalid-template"}}`,
+			&Error{Code: "40004", SubCode: "isv.invalid-template"}},
+		{"gateway error", "2088000000000001", "to_user_id",
+			`{"error_response":{"code":"20000","msg":"Service Currently Unavailable","sub_code":"isp.unknow-error"}}`,
+			&Error{Code: "20000", SubCode: "isp.unknow-error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Fatal(err)
+				}
+				if err := verifySign(&key.PublicKey, r.PostForm); err != nil {
+					t.Errorf("invalid signature: %v", err)
+				}
+				if got := r.PostForm.Get("method"); got != templateMessageMethod {
+					t.Errorf("method = %q", got)
+				}
+				biz := map[string]string{}
+				if err := json.Unmarshal([]byte(r.PostForm.Get("biz_content")), &biz); err != nil {
+					t.Fatal(err)
+				}
+				if biz[tt.userKey] != tt.toUser || biz["user_template_id"] != "tpl" {
+					t.Errorf("biz_content = %v", biz)
+				}
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			c := &Client{AppID: "app", PrivateKey: key, Gateway: srv.URL}
+			err := c.SendTemplateMessage(context.Background(), &TemplateMessage{
+				ToUser:     tt.toUser,
+				TemplateID: "tpl",
+				Page:       "pages/index/index",
+				Data:       map[string]DataItem{"keyword1": {Value: "拿铁"}},
+			})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("SendTemplateMessage() = %v", err)
+				}
+				return
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Code != tt.wantErr.Code || e.SubCode != tt.wantErr.SubCode {
+				t.Fatalf("SendTemplateMessage() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendTemplateMessageWithoutKey(t *testing.T) {
+	c := &Client{AppID: "app", Gateway: "http://127.0.0.1:0"}
+	if err := c.SendTemplateMessage(context.Background(), &TemplateMessage{ToUser: "u"}); err == nil {
+		t.Fatal("SendTemplateMessage() without private key succeeded")
+	}
+}
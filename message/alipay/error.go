@@ -0,0 +1,35 @@
+package alipay
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// codeSuccess 接口调用成功
+	codeSuccess = "10000"
+	// codeUnavailable 服务不可用
+	codeUnavailable = "20000"
+)
+
+// Error 支付宝接口返回的错误
+type Error struct {
+	// Code 网关返回码
+	Code string `json:"code"`
+	// Msg 网关返回码描述
+	Msg string `json:"msg"`
+	// SubCode 业务返回码
+	SubCode string `json:"sub_code"`
+	// SubMsg 业务返回码描述
+	SubMsg string `json:"sub_msg"`
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	return fmt.Sprintf("alipay error %s/%s: %s %s", e.Code, e.SubCode, e.Msg, e.SubMsg)
+}
+
+// Temporary 是否为临时错误（服务不可用或服务端内部错误）
+func (e *Error) Temporary() bool {
+	return e.Code == codeUnavailable || strings.HasPrefix(e.SubCode, "isp.")
+}
@@ -0,0 +1,163 @@
+package douyin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBaseURL 抖音小程序接口地址
+	defaultBaseURL = "https://developer.toutiao.com"
+	// refreshBefore 提前刷新 access_token 的时间，避免临界过期
+	refreshBefore = 5 * time.Minute
+	// defaultTimeout 接口调用超时时间
+	defaultTimeout = 10 * time.Second
+)
+
+// Client 抖音小程序服务端接口
+// access_token 缓存在内存中，过期前自动刷新，并发调用只会触发一次刷新
+type Client struct {
+	// AppID 小程序 appid
+	AppID string
+	// Secret 小程序 secret
+	Secret string
+	// BaseURL 接口地址，为空时使用正式地址（测试时可指向本地服务）
+	BaseURL string
+	// HTTPClient 为空时使用默认超时的 http.Client
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	token    string
+	expireAt time.Time
+}
+
+// NewClient 创建抖音小程序接口客户端
+func NewClient(appID, secret string) *Client {
+	return &Client{AppID: appID, Secret: secret}
+}
+
+// Notification 订阅消息
+type Notification struct {
+	// OpenID 接收者 open_id
+	OpenID string
+	// TemplateID 模版编号
+	TemplateID string
+	// Page 点击消息跳转的页面
+	Page string
+	// Data 模版内容（关键词 -> 内容）
+	Data map[string]string
+}
+
+// tokenResponse 获取 access_token 的返回
+type tokenResponse struct {
+	Error
+	Data struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	} `json:"data"`
+}
+
+// AccessToken 获取 access_token，缓存有效时直接返回
+func (c *Client) AccessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Add(refreshBefore).Before(c.expireAt) {
+		return c.token, nil
+	}
+
+	rsp := &tokenResponse{}
+	err := c.post(ctx, "/api/apps/v2/token", map[string]string{
+		"appid":      c.AppID,
+		"secret":     c.Secret,
+		"grant_type": "client_credential",
+	}, rsp)
+	if err != nil {
+		return "", err
+	}
+	if rsp.Code != 0 {
+		return "", &Error{Code: rsp.Code, Msg: rsp.Msg}
+	}
+	if rsp.Data.AccessToken == "" {
+		return "", errors.New("douyin returned empty access token")
+	}
+	c.token = rsp.Data.AccessToken
+	c.expireAt = time.Now().Add(time.Duration(rsp.Data.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// InvalidateToken 清除缓存的 access_token
+func (c *Client) InvalidateToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// Notify 发送订阅消息
+// access_token 失效时会刷新后重试一次
+func (c *Client) Notify(ctx context.Context, n *Notification) error {
+	for attempt := 0; ; attempt++ {
+		token, err := c.AccessToken(ctx)
+		if err != nil {
+			return err
+		}
+		rsp := &Error{}
+		err = c.post(ctx, "/api/apps/subscribe_notification/developer/v1/notify", map[string]interface{}{
+			"access_token": token,
+			"app_id":       c.AppID,
+			"tpl_id":       n.TemplateID,
+			"open_id":      n.OpenID,
+			"data":         n.Data,
+			"page":         n.Page,
+		}, rsp)
+		if err != nil {
+			return err
+		}
+		if rsp.Code == 0 {
+			return nil
+		}
+		if rsp.tokenInvalid() && attempt == 0 {
+			c.InvalidateToken(token)
+			continue
+		}
+		return rsp
+	}
+}
+
+// post 以 JSON 调用接口并解析返回
+func (c *Client) post(ctx context.Context, path string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	base := c.BaseURL
+	if base == "" {
+		base = defaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: defaultTimeout}
+	}
+	rsp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("douyin http status %d", rsp.StatusCode)
+	}
+	return json.NewDecoder(rsp.Body).Decode(out)
+}
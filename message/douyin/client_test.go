@@ -0,0 +1,79 @@
+package douyin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestTokenInvalid(t *testing.T) {
+	tests := []struct {
+		err       Error
+		invalid   bool
+		temporary bool
+	}{
+		{Error{Code: codeTokenInvalid}, true, true},
+		{Error{Code: codeTokenInvalidV2}, true, true},
+		{Error{Code: codeTokenExpiredV2}, true, true},
+		{Error{Code: codeBusy}, false, true},
+		// 错误信息中提到 access_token 但并非 token 失效
+		{Error{Code: 40014, Msg: "access_token is required"}, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.err.tokenInvalid(); got != tt.invalid {
+			t.Errorf("%d: tokenInvalid = %v, want %v", tt.err.Code, got, tt.invalid)
+		}
+		if got := tt.err.Temporary(); got != tt.temporary {
+			t.Errorf("%d: Temporary = %v, want %v", tt.err.Code, got, tt.temporary)
+		}
+	}
+}
+
+func TestNotifyRetriesOnInvalidToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		codes   []int
+		wantErr bool
+		tokens  int
+	}{
+		{"success", nil, false, 1},
+		{"v1 invalid", []int{codeTokenInvalid}, false, 2},
+		{"v2 expired", []int{codeTokenExpiredV2}, false, 2},
+		{"retry once", []int{codeTokenInvalidV2, codeTokenInvalidV2}, true, 2},
+		{"permanent", []int{40014}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, sends := 0, 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/apps/v2/token" {
+					tokens++
+					_, _ = w.Write([]byte(`{"err_no":0,"data":{"access_token":"t` + strconv.Itoa(tokens) + `","expires_in":7200}}`))
+					return
+				}
+				sends++
+				code := 0
+				if sends <= len(tt.codes) {
+					code = tt.codes[sends-1]
+				}
+				_ = json.NewEncoder(w).Encode(Error{Code: code, Msg: "mock"})
+			}))
+			defer srv.Close()
+			c := NewClient("appid", "secret")
+			c.BaseURL = srv.URL
+
+			err := c.Notify(context.Background(), &Notification{OpenID: "u", TemplateID: "tpl"})
+			var de *Error
+			if tt.wantErr != errors.As(err, &de) {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tokens != tt.tokens {
+				t.Errorf("tokens fetched = %d, want %d", tokens, tt.tokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package douyin
+
+import (
+	"fmt"
+)
+
+const (
+	// codeBusy 系统繁忙
+	codeBusy = -1
+	// codeTokenInvalid access_token 无效或过期（v1 接口）
+	codeTokenInvalid = 40002
+	// codeTokenInvalidV2 access_token 无效（v2 接口）
+	codeTokenInvalidV2 = 28001003
+	// codeTokenExpiredV2 access_token 已过期（v2 接口）
+	codeTokenExpiredV2 = 28001008
+)
+
+// Error 抖音开放平台接口返回的错误
+type Error struct {
+	// Code 错误码
+	Code int `json:"err_no"`
+	// Msg 错误信息
+	Msg string `json:"err_tips"`
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	return fmt.Sprintf("douyin error %d: %s", e.Code, e.Msg)
+}
+
+// Temporary 是否为临时错误（系统繁忙或 access_token 失效）
+func (e *Error) Temporary() bool {
+	return e.Code == codeBusy || e.tokenInvalid()
+}
+
+// tokenInvalid 错误是否由 access_token 失效引起
+func (e *Error) tokenInvalid() bool {
+	switch e.Code {
+	case codeTokenInvalid, codeTokenInvalidV2, codeTokenExpiredV2:
+		return true
+	}
+	return false
+}
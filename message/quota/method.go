@@ -0,0 +1,157 @@
+package quota
+
+import (
+	"errors"
+	"time"
+
+	home "github.com/r2day/m3s/client/home"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNoQuota 用户没有该模版的剩余订阅次数
+var ErrNoQuota = errors.New("subscribe quota exhausted")
+
+// key 用户与模版的查询条件
+func key(channel home.AppType, receiver, templateID string) bson.D {
+	filter := bson.D{
+		{Key: "receiver", Value: receiver},
+		{Key: "template_id", Value: templateID},
+	}
+	if channel == home.WxApp {
+		// 微信为零值渠道，omitempty 不会写入
+		return append(filter, bson.E{Key: "channel", Value: bson.D{{Key: "$in", Value: bson.A{nil, home.WxApp}}}})
+	}
+	return append(filter, bson.E{Key: "channel", Value: channel})
+}
+
+// Grant 记录用户在小程序中的订阅选择（模版编号 -> 选择）
+// 同意时增加一次发送机会
+func (m *Model) Grant(channel home.AppType, receiver string, results map[string]Result) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	now := time.Now().Unix()
+	for templateID, result := range results {
+		inc := bson.D{{Key: "rejected", Value: 1}}
+		if result == Accept {
+			inc = bson.D{{Key: "remaining", Value: 1}, {Key: "accepted", Value: 1}}
+		}
+		update := bson.D{
+			{Key: "$inc", Value: inc},
+			{Key: "$set", Value: bson.D{
+				{Key: "last_result", Value: result},
+				{Key: "last_grant_time", Value: now},
+			}},
+		}
+		filter := key(channel, receiver, templateID)
+		_, err := coll.UpdateOne(m.Context.Context, filter, update, options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			// 并发创建额度记录，由唯一索引保证只有一条，重试时更新已创建的记录
+			_, err = coll.UpdateOne(m.Context.Context, filter, update)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRemaining 获取用户该模版的剩余发送次数
+func (m *Model) GetRemaining(channel home.AppType, receiver, templateID string) (int64, error) {
+	result := &Model{}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	err := coll.FindOne(m.Context.Context, key(channel, receiver, templateID)).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.Remaining, nil
+}
+
+// Check 检查用户是否还有该模版的发送次数
+// 入队时只做预检查，不占用次数：同一时间入队的多条消息可能都通过检查，
+// 实际以发送时 Consume 的原子扣减为准，次数不足的消息在发送时失败
+func (m *Model) Check(channel home.AppType, receiver, templateID string) error {
+	remaining, err := m.GetRemaining(channel, receiver, templateID)
+	if err != nil {
+		return err
+	}
+	if remaining <= 0 {
+		return ErrNoQuota
+	}
+	return nil
+}
+
+// Consume 发送时原子扣减一次发送机会
+func (m *Model) Consume(channel home.AppType, receiver, templateID string) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := append(key(channel, receiver, templateID),
+		bson.E{Key: "remaining", Value: bson.D{{Key: "$gt", Value: 0}}})
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "remaining", Value: -1}, {Key: "consumed", Value: 1}}},
+		{Key: "$set", Value: bson.D{{Key: "last_consume_time", Value: time.Now().Unix()}}},
+	}
+	result, err := coll.UpdateOne(m.Context.Context, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNoQuota
+	}
+	return nil
+}
+
+// Refund 发送未到达平台时退还发送机会
+func (m *Model) Refund(channel home.AppType, receiver, templateID string) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "remaining", Value: 1}, {Key: "consumed", Value: -1}}}}
+	_, err := coll.UpdateOne(m.Context.Context, key(channel, receiver, templateID), update)
+	return err
+}
+
+// Exhaust 平台返回用户未订阅（如微信 43101）时清空剩余次数，与平台保持一致
+func (m *Model) Exhaust(channel home.AppType, receiver, templateID string) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "remaining", Value: 0}}}}
+	_, err := coll.UpdateOne(m.Context.Context, key(channel, receiver, templateID), update)
+	return err
+}
+
+// GetByReceiver 查询用户各模版的剩余发送次数
+func (m *Model) GetByReceiver(receiver string) ([]*Model, error) {
+
+	results := make([]*Model, 0)
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{{Key: "receiver", Value: receiver}}
+
+	// 获取数据列表
+	cursor, err := coll.Find(m.Context.Context, filter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CreateIndexes 创建用户与模版的唯一索引，避免并发订阅产生重复的额度记录
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateOne(m.Context.Context, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "channel", Value: 1},
+			{Key: "receiver", Value: 1},
+			{Key: "template_id", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,33 @@
+package quota
+
+import (
+	"reflect"
+	"testing"
+
+	home "github.com/r2day/m3s/client/home"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel home.AppType
+		want    bson.E
+	}{
+		// 微信为零值渠道，旧记录与 omitempty 写入的记录都没有 channel 字段
+		{"wechat", home.WxApp, bson.E{Key: "channel", Value: bson.D{{Key: "$in", Value: bson.A{nil, home.WxApp}}}}},
+		{"alipay", home.AlipayApp, bson.E{Key: "channel", Value: home.AlipayApp}},
+		{"douyin", home.DouYin, bson.E{Key: "channel", Value: home.DouYin}},
+	}
+	for _, tt := range tests {
+		got := key(tt.channel, "u1", "tpl")
+		want := bson.D{
+			{Key: "receiver", Value: "u1"},
+			{Key: "template_id", Value: "tpl"},
+			tt.want,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: key = %v, want %v", tt.name, got, want)
+		}
+	}
+}
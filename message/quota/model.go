@@ -0,0 +1,75 @@
+package quota
+
+import (
+	"github.com/open4go/model"
+	home "github.com/r2day/m3s/client/home"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "message_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_data"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "quota"
+)
+
+// Result 用户对订阅请求的选择（与小程序 requestSubscribeMessage 返回值一致）
+type Result string
+
+const (
+	// Accept 用户同意订阅，获得一次发送机会
+	Accept Result = "accept"
+	// Reject 用户拒绝订阅
+	Reject Result = "reject"
+	// Ban 模版已被后台封禁
+	Ban Result = "ban"
+	// Filter 模版因标题同名被过滤
+	Filter Result = "filter"
+)
+
+// Model 订阅消息额度
+// 一次性订阅每次用户同意可发送一条消息，按用户与模版记录剩余次数
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// Channel 消息渠道
+	Channel home.AppType `json:"channel" bson:"channel,omitempty"`
+	// Receiver 用户（openid）
+	Receiver string `json:"receiver" bson:"receiver,omitempty"`
+	// TemplateID 模版编号
+	TemplateID string `json:"template_id" bson:"template_id,omitempty"`
+	// Remaining 剩余可发送次数
+	Remaining int64 `json:"remaining" bson:"remaining"`
+	// Accepted 累计同意次数
+	Accepted int64 `json:"accepted" bson:"accepted,omitempty"`
+	// Rejected 累计拒绝次数
+	Rejected int64 `json:"rejected" bson:"rejected,omitempty"`
+	// Consumed 累计发送消耗次数
+	Consumed int64 `json:"consumed" bson:"consumed,omitempty"`
+	// LastResult 最近一次订阅选择
+	LastResult Result `json:"last_result" bson:"last_result,omitempty"`
+	// LastGrantTime 最近一次订阅时间
+	LastGrantTime int64 `json:"last_grant_time" bson:"last_grant_time,omitempty"`
+	// LastConsumeTime 最近一次消耗时间
+	LastConsumeTime int64 `json:"last_consume_time" bson:"last_consume_time,omitempty"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// String 规则描述
+func (r *Rule) String() string {
+	return fmt.Sprintf("max %d per %s by %s", r.Max, r.Duration(), r.Scope)
+}
+
+// Duration 时间窗口
+func (r *Rule) Duration() time.Duration {
+	return time.Duration(r.Window) * time.Second
+}
+
+// key 规则对应的计数对象，缺少对应字段时返回空
+func (r *Rule) key(s Subject) string {
+	switch r.Scope {
+	case PerReceiver:
+		return s.Receiver
+	case PerTemplate:
+		return s.Template
+	case PerReceiverTemplate:
+		if s.Receiver == "" || s.Template == "" {
+			return ""
+		}
+		return s.Receiver + "|" + s.Template
+	}
+	return ""
+}
+
+// counter 窗口计数
+type counter interface {
+	// incr 计数加一并返回增加后的值，首次计数时设置过期时间
+	incr(id string, expireAt time.Time) (int64, error)
+	// decr 撤销一次计数，计数已过期清理时不做处理
+	decr(id string) error
+}
+
+// Allow 按规则计数并判断是否允许发送
+// 超出限制时返回命中的规则，允许时返回 nil；namespace 用于区分不同的消息类型
+// 使用固定窗口计数，窗口边界处短时间内最多可能发送 2 倍数量
+func (m *Model) Allow(namespace string, rules []Rule, s Subject) (*Rule, error) {
+	return allow(m, namespace, rules, s, time.Now())
+}
+
+// allow 依次计数，被任一规则拒绝或出错时撤销本次已增加的计数
+// 被抑制的消息不占用其他规则的额度
+func allow(c counter, namespace string, rules []Rule, s Subject, now time.Time) (*Rule, error) {
+	counted := make([]string, 0, len(rules))
+	rollback := func() {
+		for _, id := range counted {
+			_ = c.decr(id)
+		}
+	}
+	for i := range rules {
+		r := &rules[i]
+		subject := r.key(s)
+		if subject == "" || r.Max <= 0 || r.Window <= 0 {
+			continue
+		}
+		start := now.Truncate(r.Duration())
+		id := fmt.Sprintf("%s:%s:%s:%d", namespace, r.Scope, subject, start.Unix())
+		count, err := c.incr(id, start.Add(r.Duration()))
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		counted = append(counted, id)
+		if count > r.Max {
+			rollback()
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// incr 原子的增加计数
+func (m *Model) incr(id string, expireAt time.Time) (int64, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	counter := &Model{}
+	err := coll.FindOneAndUpdate(m.Context.Context,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "expire_at", Value: expireAt}}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Count, nil
+}
+
+// decr 撤销计数，不创建新的计数，避免计数过期后产生没有过期时间的负数记录
+func (m *Model) decr(id string) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: -1}}}})
+	return err
+}
+
+// CreateIndexes 创建计数过期清理的 TTL 索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateOne(m.Context.Context, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expire_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// memCounter 内存计数
+type memCounter struct {
+	counts map[string]int64
+	fail   string
+}
+
+func (c *memCounter) incr(id string, expireAt time.Time) (int64, error) {
+	if c.fail != "" && id == c.fail {
+		return 0, errors.New("counter unavailable")
+	}
+	c.counts[id]++
+	return c.counts[id], nil
+}
+
+// decr 与数据库一致，只撤销仍存在的计数
+func (c *memCounter) decr(id string) error {
+	if _, ok := c.counts[id]; ok {
+		c.counts[id]--
+	}
+	return nil
+}
+
+func TestAllow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	perReceiver := Rule{Scope: PerReceiver, Max: 3, Window: 3600}
+	perPair := Rule{Scope: PerReceiverTemplate, Max: 1, Window: 60}
+	perTemplate := Rule{Scope: PerTemplate, Max: 100, Window: 86400}
+
+	tests := []struct {
+		name    string
+		rules   []Rule
+		subject Subject
+		sends   int
+		allowed int
+		hit     *Rule
+	}{
+		{"no rules", nil, Subject{Receiver: "u"}, 5, 5, nil},
+		{"per receiver", []Rule{perReceiver}, Subject{Receiver: "u"}, 5, 3, &perReceiver},
+		{"pair limit first", []Rule{perReceiver, perPair}, Subject{Receiver: "u", Template: "t"}, 3, 1, &perPair},
+		{"missing subject field", []Rule{perPair}, Subject{Receiver: "u"}, 3, 3, nil},
+		{"invalid rule ignored", []Rule{{Scope: PerReceiver, Max: 0, Window: 60}, {Scope: PerReceiver, Max: 1}}, Subject{Receiver: "u"}, 3, 3, nil},
+		{"all pass", []Rule{perTemplate, perReceiver}, Subject{Receiver: "u", Template: "t"}, 3, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &memCounter{counts: map[string]int64{}}
+			allowed := 0
+			var hit *Rule
+			for i := 0; i < tt.sends; i++ {
+				r, err := allow(c, "test", tt.rules, tt.subject, now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r == nil {
+					allowed++
+				} else {
+					hit = r
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %d, want %d", allowed, tt.allowed)
+			}
+			if (hit == nil) != (tt.hit == nil) || (hit != nil && *hit != *tt.hit) {
+				t.Errorf("hit = %v, want %v", hit, tt.hit)
+			}
+		})
+	}
+}
+
+func TestAllowRollsBackSuppressed(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rules := []Rule{
+		{Scope: PerReceiver, Max: 10, Window: 3600},
+		{Scope: PerReceiverTemplate, Max: 1, Window: 3600},
+	}
+	c := &memCounter{counts: map[string]int64{}}
+	subject := Subject{Receiver: "u", Template: "t"}
+	for i := 0; i < 5; i++ {
+		if _, err := allow(c, "test", rules, subject, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 被第二条规则抑制的 4 条不计入接收者的额度
+	for id, n := range c.counts {
+		if n != 1 {
+			t.Errorf("%s = %d, want 1", id, n)
+		}
+	}
+
+	// 其他模版仍可发送
+	if r, _ := allow(c, "test", rules, Subject{Receiver: "u", Template: "other"}, now); r != nil {
+		t.Errorf("other template limited by %v", r)
+	}
+}
+
+func TestAllowRollsBackOnError(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rules := []Rule{
+		{Scope: PerReceiver, Max: 10, Window: 3600},
+		{Scope: PerTemplate, Max: 10, Window: 3600},
+	}
+	c := &memCounter{counts: map[string]int64{}, fail: "test:template:t:1699999200"}
+	if _, err := allow(c, "test", rules, Subject{Receiver: "u", Template: "t"}, now); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := c.counts["test:receiver:u:1699999200"]; n != 0 {
+		t.Errorf("receiver count = %d, want 0 after rollback", n)
+	}
+}
+
+func TestAllowWindows(t *testing.T) {
+	rules := []Rule{{Scope: PerReceiver, Max: 1, Window: 60}}
+	c := &memCounter{counts: map[string]int64{}}
+	start := time.Unix(1700000040, 0) // 窗口 [1700000040, 1700000100)
+	if r, _ := allow(c, "test", rules, Subject{Receiver: "u"}, start); r != nil {
+		t.Fatal("first send limited")
+	}
+	if r, _ := allow(c, "test", rules, Subject{Receiver: "u"}, start.Add(59*time.Second)); r == nil {
+		t.Error("second send in the same window allowed")
+	}
+	if r, _ := allow(c, "test", rules, Subject{Receiver: "u"}, start.Add(60*time.Second)); r != nil {
+		t.Error("send in the next window limited")
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	r := Rule{Scope: PerReceiver, Max: 5, Window: 3600}
+	if got, want := r.String(), "max 5 per 1h0m0s by receiver"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
+
+func TestAllowRollbackAfterExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rules := []Rule{{Scope: PerReceiver, Max: 10, Window: 3600}, {Scope: PerTemplate, Max: 10, Window: 3600}}
+	c := &memCounter{counts: map[string]int64{}, fail: "test:template:t:1699999200"}
+	// 接收者计数在撤销前已过期清理
+	if _, err := allow(&expiring{c}, "test", rules, Subject{Receiver: "u", Template: "t"}, now); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := c.counts["test:receiver:u:1699999200"]; ok {
+		t.Error("rollback recreated an expired counter")
+	}
+}
+
+// expiring 计数后立即过期的计数
+type expiring struct {
+	*memCounter
+}
+
+func (c *expiring) incr(id string, expireAt time.Time) (int64, error) {
+	n, err := c.memCounter.incr(id, expireAt)
+	delete(c.counts, id)
+	return n, err
+}
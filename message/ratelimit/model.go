@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/open4go/model"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "message_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_data"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "ratelimit"
+)
+
+// Scope 限流维度
+type Scope string
+
+const (
+	// PerReceiver 每个接收者（不区分模版）
+	PerReceiver Scope = "receiver"
+	// PerTemplate 每个模版（不区分接收者）
+	PerTemplate Scope = "template"
+	// PerReceiverTemplate 每个接收者的每个模版
+	PerReceiverTemplate Scope = "receiver_template"
+)
+
+// Rule 限流规则，例如：每个接收者每小时最多 5 条
+type Rule struct {
+	// Scope 限流维度
+	Scope Scope `json:"scope" bson:"scope"`
+	// Max 时间窗口内最多发送的数量
+	Max int64 `json:"max" bson:"max"`
+	// Window 时间窗口（秒）
+	Window int64 `json:"window" bson:"window"`
+}
+
+// Subject 被限流的对象
+type Subject struct {
+	// Receiver 接收者
+	Receiver string
+	// Template 模版（订阅消息模版编号/通知类型）
+	Template string
+}
+
+// Model 限流计数
+// 按固定时间窗口计数，窗口结束后由 TTL 索引自动清理
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// ID 计数键：命名空间:维度:对象:窗口开始时间
+	ID string `json:"id" bson:"_id"`
+	// Count 窗口内的数量
+	Count int64 `json:"count" bson:"count"`
+	// ExpireAt 过期时间（TTL）
+	ExpireAt time.Time `json:"expire_at" bson:"expire_at"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
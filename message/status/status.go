@@ -0,0 +1,202 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// Field 状态字段
+	Field = "message_status"
+	// TimelineField 状态变更时间字段
+	TimelineField = "status_times"
+)
+
+// Status 消息状态
+// 使用字符串存储，零值为空字符串，避免 omitempty 丢失有效状态
+type Status string
+
+const (
+	// Queued 已入队，等待发送
+	Queued Status = "queued"
+	// Sending 发送中（已被投递实例领取）
+	Sending Status = "sending"
+	// Sent 已发送（未读）
+	Sent Status = "sent"
+	// Failed 发送失败（不再重试）
+	Failed Status = "failed"
+	// Read 已读
+	Read Status = "read"
+	// Deleted 已删除
+	Deleted Status = "deleted"
+	// Cancelled 发送前被取消
+	Cancelled Status = "cancelled"
+	// Expired 超过有效期未能发送
+	Expired Status = "expired"
+	// Suppressed 超出发送频率限制，未发送
+	Suppressed Status = "suppressed"
+)
+
+var (
+	// ErrIllegalTransition 不允许的状态变更
+	ErrIllegalTransition = errors.New("illegal message status transition")
+	// ErrConflict 当前状态与预期不一致（已被其他操作更新）
+	ErrConflict = errors.New("message status changed concurrently")
+)
+
+// transitions 允许的状态变更
+var transitions = map[Status][]Status{
+	Queued:     {Sending, Cancelled, Expired, Deleted},
+	Sending:    {Sending, Sent, Queued, Failed, Expired},
+	Sent:       {Read, Deleted},
+	Failed:     {Queued, Deleted},
+	Read:       {Deleted},
+	Deleted:    {},
+	Cancelled:  {},
+	Expired:    {},
+	Suppressed: {},
+}
+
+// Timeline 各状态的变更时间（状态 -> 时间戳）
+type Timeline map[Status]int64
+
+// Valid 是否为已定义的状态
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// Final 是否为最终状态
+func (s Status) Final() bool {
+	return s.Valid() && len(transitions[s]) == 0
+}
+
+// CanTransition 判断状态是否允许从 from 变更为 to
+func CanTransition(from, to Status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition 校验状态变更
+func Transition(from, to Status) error {
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+// SetFields 状态变更需要写入的字段（状态与变更时间）
+func SetFields(to Status, now time.Time) bson.D {
+	return bson.D{
+		{Key: Field, Value: to},
+		{Key: TimelineField + "." + string(to), Value: now.Unix()},
+	}
+}
+
+// Update 原子的条件更新：仅当文档当前状态为 from 时更新为 to
+// filter 为定位文档的条件，set 为需要同时写入的其他字段
+func Update(ctx context.Context, coll *mongo.Collection, filter bson.D, from, to Status, set bson.D) error {
+	if err := Transition(from, to); err != nil {
+		return err
+	}
+	filter = append(filter, bson.E{Key: Field, Value: from})
+	update := bson.D{{Key: "$set", Value: append(SetFields(to, time.Now()), set...)}}
+	result, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// legacy 旧版本整数状态与新状态的对应关系
+// 旧版本: Sent=0, Read=1, Unread=2, Deleted=3；Unread 即已发送未读，对应 Sent
+var legacy = map[int]Status{
+	0: Sent,
+	1: Read,
+	2: Sent,
+	3: Deleted,
+}
+
+// In 匹配任一状态的查询条件，同时匹配未迁移的旧版本整数状态
+// 包含 Sent 时也匹配缺失状态字段的文档（旧版本 Sent 为零值未写入，或通过通用接口创建未设置状态）
+func In(statuses ...Status) bson.D {
+	olds := make([]int, 0, len(legacy))
+	for old := range legacy {
+		olds = append(olds, old)
+	}
+	sort.Ints(olds)
+
+	values := bson.A{}
+	for _, s := range statuses {
+		values = append(values, s)
+		for _, old := range olds {
+			if legacy[old] == s {
+				values = append(values, old)
+			}
+		}
+		if s == Sent {
+			values = append(values, nil)
+		}
+	}
+	return bson.D{{Key: "$in", Value: values}}
+}
+
+// UnmarshalBSONValue 解码状态字段，兼容旧版本的整数状态
+// 未迁移的文档仍可正常读取，未知的整数状态返回错误
+func (s *Status) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	v := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.String:
+		*s = Status(v.StringValue())
+		return nil
+	case bsontype.Null, bsontype.Undefined:
+		*s = ""
+		return nil
+	}
+	n, ok := v.AsInt64OK()
+	if !ok {
+		return fmt.Errorf("cannot decode bson %s as message status", t)
+	}
+	legacyStatus, ok := legacy[int(n)]
+	if !ok {
+		return fmt.Errorf("unknown legacy message status %d", n)
+	}
+	*s = legacyStatus
+	return nil
+}
+
+// MigrateLegacy 将旧版本的整数状态迁移为字符串状态
+// 旧版本 Sent 为零值因 omitempty 未写入，缺失状态字段的文档视为已发送
+func MigrateLegacy(ctx context.Context, coll *mongo.Collection) (int64, error) {
+	var total int64
+	for old, s := range legacy {
+		result, err := coll.UpdateMany(ctx,
+			bson.D{{Key: Field, Value: old}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: Field, Value: s}}}})
+		if err != nil {
+			return total, err
+		}
+		total += result.ModifiedCount
+	}
+	result, err := coll.UpdateMany(ctx,
+		bson.D{{Key: Field, Value: bson.D{{Key: "$exists", Value: false}}}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: Field, Value: Sent}}}})
+	if err != nil {
+		return total, err
+	}
+	return total + result.ModifiedCount, nil
+}
@@ -0,0 +1,160 @@
+package status
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		ok       bool
+	}{
+		{Queued, Sending, true},
+		{Queued, Cancelled, true},
+		{Queued, Expired, true},
+		{Queued, Sent, false},
+		{Sending, Sending, true},
+		{Sending, Sent, true},
+		{Sending, Queued, true},
+		{Sending, Failed, true},
+		{Sending, Read, false},
+		{Sent, Read, true},
+		{Sent, Deleted, true},
+		{Sent, Queued, false},
+		{Failed, Queued, true},
+		{Failed, Sent, false},
+		{Read, Deleted, true},
+		{Read, Sent, false},
+		{Deleted, Sent, false},
+		{Cancelled, Queued, false},
+		{Suppressed, Queued, false},
+		{"", Sent, false},
+	}
+	for _, tt := range tests {
+		err := Transition(tt.from, tt.to)
+		if tt.ok && err != nil {
+			t.Errorf("%s -> %s: unexpected error %v", tt.from, tt.to, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("%s -> %s: err = %v, want ErrIllegalTransition", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestValidFinal(t *testing.T) {
+	tests := []struct {
+		s            Status
+		valid, final bool
+	}{
+		{Queued, true, false},
+		{Sent, true, false},
+		{Deleted, true, true},
+		{Cancelled, true, true},
+		{Expired, true, true},
+		{Suppressed, true, true},
+		{"unknown", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.s.Valid(); got != tt.valid {
+			t.Errorf("%q.Valid() = %v, want %v", tt.s, got, tt.valid)
+		}
+		if got := tt.s.Final(); got != tt.final {
+			t.Errorf("%q.Final() = %v, want %v", tt.s, got, tt.final)
+		}
+	}
+}
+
+func TestSetFields(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	got := SetFields(Read, now)
+	want := bson.D{{Key: Field, Value: Read}, {Key: "status_times.read", Value: int64(1700000000)}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("SetFields = %v, want %v", got, want)
+	}
+}
+
+func TestUnmarshalBSONValue(t *testing.T) {
+	type doc struct {
+		Status Status `bson:"message_status"`
+	}
+	tests := []struct {
+		name    string
+		in      bson.D
+		want    Status
+		wantErr bool
+	}{
+		{"string", bson.D{{Key: Field, Value: "read"}}, Read, false},
+		{"legacy sent", bson.D{{Key: Field, Value: int32(0)}}, Sent, false},
+		{"legacy read", bson.D{{Key: Field, Value: int32(1)}}, Read, false},
+		{"legacy unread", bson.D{{Key: Field, Value: int32(2)}}, Sent, false},
+		{"legacy deleted", bson.D{{Key: Field, Value: int64(3)}}, Deleted, false},
+		{"null", bson.D{{Key: Field, Value: nil}}, "", false},
+		{"missing", bson.D{}, "", false},
+		{"unknown legacy", bson.D{{Key: Field, Value: int32(4)}}, "", true},
+		{"wrong type", bson.D{{Key: Field, Value: true}}, "", true},
+	}
+	for _, tt := range tests {
+		data, err := bson.Marshal(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d doc
+		err = bson.Unmarshal(data, &d)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil || d.Status != tt.want {
+			t.Errorf("%s: status, err = %q, %v, want %q", tt.name, d.Status, err, tt.want)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	type doc struct {
+		Status Status   `bson:"message_status"`
+		Times  Timeline `bson:"status_times"`
+	}
+	data, err := bson.Marshal(doc{Status: Sending, Times: Timeline{Sending: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d doc
+	if err = bson.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != Sending || d.Times[Sending] != 1 {
+		t.Errorf("round trip = %+v", d)
+	}
+}
+
+func TestIn(t *testing.T) {
+	tests := []struct {
+		statuses []Status
+		want     bson.A
+	}{
+		{[]Status{Sent}, bson.A{Sent, 0, 2, nil}},
+		{[]Status{Read, Deleted}, bson.A{Read, 1, Deleted, 3}},
+		{[]Status{Queued}, bson.A{Queued}},
+	}
+	for _, tt := range tests {
+		got := In(tt.statuses...)
+		values, _ := got[0].Value.(bson.A)
+		if got[0].Key != "$in" || len(values) != len(tt.want) {
+			t.Fatalf("In(%v) = %v, want $in %v", tt.statuses, got, tt.want)
+		}
+		for i := range values {
+			if values[i] != tt.want[i] {
+				t.Errorf("In(%v) = %v, want $in %v", tt.statuses, got, tt.want)
+				break
+			}
+		}
+	}
+}
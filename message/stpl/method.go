@@ -0,0 +1,42 @@
+package stpl
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrTemplateNotFound 模版未登记
+	ErrTemplateNotFound = errors.New("subscribe template not registered")
+	// ErrTemplateDisabled 模版已停用
+	ErrTemplateDisabled = errors.New("subscribe template disabled")
+)
+
+// GetByTemplateID 根据平台模版编号获取模版定义
+func (m *Model) GetByTemplateID(templateID string) (*Model, error) {
+	result := &Model{}
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{{Key: "template_id", Value: templateID}}
+	err := coll.FindOne(m.Context.Context, filter).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ValidatePayload 按登记的模版定义校验消息内容，模版未启用时返回 ErrTemplateDisabled
+func (m *Model) ValidatePayload(templateID string, payload map[string]string) (map[string]string, error) {
+	tpl, err := m.GetByTemplateID(templateID)
+	if err != nil {
+		return nil, err
+	}
+	if !tpl.Enabled {
+		return nil, ErrTemplateDisabled
+	}
+	return tpl.Validate(payload)
+}
@@ -0,0 +1,95 @@
+package stpl
+
+import (
+	"github.com/open4go/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CollectionNamePrefix 数据库表前缀
+	// 可以根据具体业务的需要进行定义
+	// 例如: sys_, scm_, customer_, order_ 等
+	collectionNamePrefix = "message_"
+	// CollectionNameSuffix 后缀
+	// 例如, _log, _config, _flow,
+	collectionNameSuffix = "_template"
+	// 这个需要用户根据具体业务完成设定
+	modelName = "subscribe"
+)
+
+// FieldType 订阅消息模版关键词类型
+// 参考微信小程序订阅消息参数值内容限制
+type FieldType string
+
+const (
+	// Thing 事物，20个以内字符，可汉字、数字、字母或符号组合
+	Thing FieldType = "thing"
+	// Number 数字，32位以内数字，可带小数
+	Number FieldType = "number"
+	// Letter 字母，32位以内字母
+	Letter FieldType = "letter"
+	// Symbol 符号，5位以内符号
+	Symbol FieldType = "symbol"
+	// CharacterString 字符串，32位以内数字、字母或符号
+	CharacterString FieldType = "character_string"
+	// Time 时间，24小时制时间格式（支持+年月日），例如：15:01 或 2019年10月1日 15:01
+	Time FieldType = "time"
+	// Date 日期，年月日格式（支持+24小时制时间），例如：2019年10月1日 或 2019-10-01 15:01
+	Date FieldType = "date"
+	// Amount 金额，1个币种符号+10位以内纯数字，可带小数，结尾可带“元”
+	Amount FieldType = "amount"
+	// PhoneNumber 电话，17位以内，数字、符号
+	PhoneNumber FieldType = "phone_number"
+	// CarNumber 车牌，8位以内
+	CarNumber FieldType = "car_number"
+	// Name 姓名，10个以内纯汉字或20个以内纯字母或符号
+	Name FieldType = "name"
+	// Phrase 汉字，5个以内汉字
+	Phrase FieldType = "phrase"
+)
+
+// Model 订阅消息模版
+// 记录模版编号与其关键词的对应关系，用于发送前校验消息内容
+type Model struct {
+	// 模型继承
+	model.Model `json:"_" bson:"_"`
+	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+
+	// Name 模版标题（例如：取餐提醒）
+	Name string `json:"name" bson:"name,omitempty"`
+	// TemplateID 平台模版编号
+	TemplateID string `json:"template_id" bson:"template_id,omitempty"`
+	// Page 默认跳转页面
+	Page string `json:"page" bson:"page,omitempty"`
+	// Fields 关键词列表
+	Fields []*Field `json:"fields" bson:"fields,omitempty"`
+	// Enabled 是否启用
+	Enabled bool `json:"enabled" bson:"enabled,omitempty"`
+}
+
+// Field 模版关键词
+type Field struct {
+	// Key 关键词编号（例如：thing5, amount12）
+	Key string `json:"key" bson:"key"`
+	// Name 关键词名称（例如：商品名称）
+	Name string `json:"name" bson:"name,omitempty"`
+	// Type 关键词类型
+	Type FieldType `json:"type" bson:"type,omitempty"`
+	// MaxLength 最大字符数，为空时使用类型的默认限制
+	MaxLength int `json:"max_length" bson:"max_length,omitempty"`
+	// Required 是否必填
+	Required bool `json:"required" bson:"required,omitempty"`
+}
+
+// ResourceName 返回资源名称
+func (m *Model) ResourceName() string {
+	//m.Meta = m.GetMeta()
+	return modelName
+}
+
+// CollectionName 返回表名称
+func (m *Model) CollectionName() string {
+	return collectionNamePrefix + modelName + collectionNameSuffix
+}
@@ -0,0 +1,165 @@
+package stpl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// ellipsis 超长 thing 截断后追加的省略号
+	ellipsis = "…"
+	// nameHanLength 姓名为汉字时的最大长度
+	nameHanLength = 10
+)
+
+// defaultMaxLength 各类型的默认最大字符数（0 表示不限制长度，只校验格式）
+var defaultMaxLength = map[FieldType]int{
+	Thing:           20,
+	Number:          32,
+	Letter:          32,
+	Symbol:          5,
+	CharacterString: 32,
+	Amount:          14,
+	PhoneNumber:     17,
+	CarNumber:       8,
+	Name:            20,
+	Phrase:          5,
+}
+
+var (
+	numberPattern    = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+	letterPattern    = regexp.MustCompile(`^[A-Za-z]+$`)
+	charStringFormat = regexp.MustCompile(`^[\x20-\x7e]+$`)
+	amountPattern    = regexp.MustCompile(`^[^\d\s.]?\d{1,10}(\.\d{1,2})?元?$`)
+	phonePattern     = regexp.MustCompile(`^[\d+\-() ]+$`)
+	datePattern      = regexp.MustCompile(`^\d{4}[-/.年]\d{1,2}[-/.月]\d{1,2}日?( \d{1,2}:\d{2}(:\d{2})?)?$`)
+	timePattern      = regexp.MustCompile(`^(\d{4}[-/.年]\d{1,2}[-/.月]\d{1,2}日? )?\d{1,2}:\d{2}(:\d{2})?$`)
+)
+
+// ValidationError 消息内容不符合模版要求
+type ValidationError struct {
+	// Key 关键词编号
+	Key string
+	// Reason 原因
+	Reason string
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("subscribe payload field %s: %s", e.Key, e.Reason)
+}
+
+// Limit 返回关键词的最大字符数
+func (f *Field) Limit() int {
+	if f.MaxLength > 0 {
+		return f.MaxLength
+	}
+	return defaultMaxLength[f.Type]
+}
+
+// Validate 按模版关键词校验消息内容，返回可直接发送的内容
+// 超长的 thing 会被截断并追加省略号，其他类型超长视为错误
+// 不在模版中的关键词视为错误
+func (m *Model) Validate(payload map[string]string) (map[string]string, error) {
+	fields := make(map[string]*Field, len(m.Fields))
+	for _, f := range m.Fields {
+		fields[f.Key] = f
+	}
+	for key := range payload {
+		if _, ok := fields[key]; !ok {
+			return nil, &ValidationError{Key: key, Reason: "not defined in template " + m.TemplateID}
+		}
+	}
+
+	results := make(map[string]string, len(payload))
+	for _, f := range m.Fields {
+		value := strings.TrimSpace(payload[f.Key])
+		if value == "" {
+			if f.Required {
+				return nil, &ValidationError{Key: f.Key, Reason: "is required"}
+			}
+			continue
+		}
+		value, err := f.Normalize(value)
+		if err != nil {
+			return nil, err
+		}
+		results[f.Key] = value
+	}
+	return results, nil
+}
+
+// Normalize 校验并规整单个关键词的值
+func (f *Field) Normalize(value string) (string, error) {
+	limit := f.Limit()
+	length := utf8.RuneCountInString(value)
+	if limit > 0 && length > limit {
+		if f.Type != Thing {
+			return "", &ValidationError{Key: f.Key, Reason: fmt.Sprintf("exceeds %d characters", limit)}
+		}
+		value = Truncate(value, limit)
+	}
+
+	ok := true
+	switch f.Type {
+	case Number:
+		ok = numberPattern.MatchString(value)
+	case Letter:
+		ok = letterPattern.MatchString(value)
+	case Symbol:
+		ok = strings.IndexFunc(value, func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsDigit(r)
+		}) < 0
+	case CharacterString:
+		ok = charStringFormat.MatchString(value)
+	case Amount:
+		ok = amountPattern.MatchString(value)
+	case PhoneNumber:
+		ok = phonePattern.MatchString(value)
+	case Date:
+		ok = datePattern.MatchString(value)
+	case Time:
+		ok = timePattern.MatchString(value)
+	case Phrase:
+		ok = isHan(value)
+	case Name:
+		// 纯汉字不超过 10 个，否则不能包含汉字
+		if hasHan(value) {
+			ok = isHan(value) && length <= nameHanLength
+		}
+	}
+	if !ok {
+		return "", &ValidationError{Key: f.Key, Reason: fmt.Sprintf("invalid %s value %q", f.Type, value)}
+	}
+	return value, nil
+}
+
+// Truncate 按字符数截断，超出时保留 limit-1 个字符并追加省略号
+func Truncate(value string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(value) <= limit {
+		return value
+	}
+	runes := []rune(value)
+	if limit == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:limit-1]) + ellipsis
+}
+
+// isHan 是否全部为汉字
+func isHan(s string) bool {
+	for _, r := range s {
+		if !unicode.Is(unicode.Han, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasHan 是否包含汉字
+func hasHan(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return unicode.Is(unicode.Han, r) }) >= 0
+}
@@ -0,0 +1,100 @@
+package stpl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		value string
+		limit int
+		want  string
+	}{
+		{"生椰拿铁", 20, "生椰拿铁"},
+		{"生椰拿铁", 4, "生椰拿铁"},
+		{"生椰拿铁", 3, "生椰…"},
+		{"生椰拿铁", 1, "生"},
+		{"latte", 0, "latte"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.value, tt.limit); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.value, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestFieldNormalize(t *testing.T) {
+	long := strings.Repeat("好", 25)
+	tests := []struct {
+		name  string
+		field Field
+		value string
+		want  string
+		err   bool
+	}{
+		{"thing", Field{Type: Thing}, "生椰拿铁", "生椰拿铁", false},
+		{"thing truncated", Field{Type: Thing}, long, strings.Repeat("好", 19) + ellipsis, false},
+		{"thing custom limit", Field{Type: Thing, MaxLength: 3}, "生椰拿铁", "生椰…", false},
+		{"number", Field{Type: Number}, "-12.5", "-12.5", false},
+		{"number invalid", Field{Type: Number}, "12a", "", true},
+		{"letter", Field{Type: Letter}, "abc", "abc", false},
+		{"letter invalid", Field{Type: Letter}, "ab1", "", true},
+		{"symbol", Field{Type: Symbol}, "#", "#", false},
+		{"symbol invalid", Field{Type: Symbol}, "a", "", true},
+		{"character string", Field{Type: CharacterString}, "A-01", "A-01", false},
+		{"character string invalid", Field{Type: CharacterString}, "取餐", "", true},
+		{"amount", Field{Type: Amount}, "¥19.50", "¥19.50", false},
+		{"amount yuan", Field{Type: Amount}, "19.50元", "19.50元", false},
+		{"amount invalid", Field{Type: Amount}, "19.505", "", true},
+		{"phone", Field{Type: PhoneNumber}, "+86 138-0000-0000", "+86 138-0000-0000", false},
+		{"phone invalid", Field{Type: PhoneNumber}, "138x", "", true},
+		{"date", Field{Type: Date}, "2024年1月2日", "2024年1月2日", false},
+		{"date time", Field{Type: Date}, "2024-01-02 12:30", "2024-01-02 12:30", false},
+		{"date invalid", Field{Type: Date}, "01/02", "", true},
+		{"time", Field{Type: Time}, "12:30", "12:30", false},
+		{"time invalid", Field{Type: Time}, "noon", "", true},
+		{"phrase", Field{Type: Phrase}, "已完成", "已完成", false},
+		{"phrase invalid", Field{Type: Phrase}, "done", "", true},
+		{"name han", Field{Type: Name}, "张三", "张三", false},
+		{"name letters", Field{Type: Name}, "Alice Smith", "Alice Smith", false},
+		{"name han too long", Field{Type: Name}, strings.Repeat("张", 11), "", true},
+		{"name mixed", Field{Type: Name}, "张Alice", "", true},
+		{"too long", Field{Type: Number}, strings.Repeat("1", 33), "", true},
+	}
+	for _, tt := range tests {
+		tt.field.Key = "k"
+		got, err := tt.field.Normalize(tt.value)
+		if tt.err {
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Errorf("%s: Normalize(%q) error = %v, want ValidationError", tt.name, tt.value, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: Normalize(%q) = %q, %v, want %q", tt.name, tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestModelValidate(t *testing.T) {
+	tpl := &Model{TemplateID: "t1", Fields: []*Field{
+		{Key: "thing1", Type: Thing, Required: true},
+		{Key: "amount2", Type: Amount},
+	}}
+	got, err := tpl.Validate(map[string]string{"thing1": " 拿铁 ", "amount2": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["thing1"] != "拿铁" {
+		t.Fatalf("Validate() = %v", got)
+	}
+	if _, err = tpl.Validate(map[string]string{"amount2": "1"}); err == nil {
+		t.Fatal("Validate() without required field succeeded")
+	}
+	if _, err = tpl.Validate(map[string]string{"thing1": "a", "x": "1"}); err == nil {
+		t.Fatal("Validate() with undefined field succeeded")
+	}
+}
@@ -0,0 +1,140 @@
+package subscribe
+
+import (
+	"errors"
+	"fmt"
+
+	home "github.com/r2day/m3s/client/home"
+	"github.com/r2day/m3s/message/alipay"
+	"github.com/r2day/m3s/message/douyin"
+	"github.com/r2day/m3s/message/quota"
+	"github.com/r2day/m3s/message/wechat"
+)
+
+// ErrNoChannel 消息渠道未配置
+var ErrNoChannel = errors.New("subscribe message channel not configured")
+
+// Adapter 消息渠道
+// 负责将通用的消息主体转换为各平台的格式并发送
+type Adapter interface {
+	Deliverer
+	// Channel 渠道类型
+	Channel() home.AppType
+}
+
+// AlipayAdapter 支付宝小程序模版消息
+type AlipayAdapter struct {
+	// Client 支付宝接口客户端
+	Client *alipay.Client
+}
+
+// Channel 渠道类型
+func (a *AlipayAdapter) Channel() home.AppType {
+	return home.AlipayApp
+}
+
+// Deliver 发送模版消息，支付宝不返回消息编号
+func (a *AlipayAdapter) Deliver(m *Model) (string, error) {
+	data := make(map[string]alipay.DataItem, len(m.Payload))
+	for k, v := range m.Payload {
+		data[k] = alipay.DataItem{Value: v}
+	}
+	return "", a.Client.SendTemplateMessage(m.Context.Context, &alipay.TemplateMessage{
+		ToUser:     m.Receiver,
+		TemplateID: m.TemplateID,
+		Page:       m.Page,
+		Data:       data,
+	})
+}
+
+// DouyinAdapter 抖音小程序订阅消息
+type DouyinAdapter struct {
+	// Client 抖音接口客户端
+	Client *douyin.Client
+}
+
+// Channel 渠道类型
+func (a *DouyinAdapter) Channel() home.AppType {
+	return home.DouYin
+}
+
+// Deliver 发送订阅消息，抖音不返回消息编号
+func (a *DouyinAdapter) Deliver(m *Model) (string, error) {
+	return "", a.Client.Notify(m.Context.Context, &douyin.Notification{
+		OpenID:     m.Receiver,
+		TemplateID: m.TemplateID,
+		Page:       m.Page,
+		Data:       m.Payload,
+	})
+}
+
+// Router 按消息渠道选择发送方式
+type Router map[home.AppType]Adapter
+
+// NewRouter 创建渠道路由
+func NewRouter(adapters ...Adapter) Router {
+	r := make(Router, len(adapters))
+	for _, a := range adapters {
+		r[a.Channel()] = a
+	}
+	return r
+}
+
+// Deliver 使用消息渠道对应的方式发送
+func (r Router) Deliver(m *Model) (string, error) {
+	a, ok := r[m.Channel]
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrNoChannel, m.Channel)
+	}
+	return a.Deliver(m)
+}
+
+// Send 发送消息并更新消息状态
+func (r Router) Send(m *Model) error {
+	return send(r, m, m)
+}
+
+// IsTemporary 判断发送错误是否可重试
+// 各平台业务错误按其错误码判断，渠道未配置、订阅次数不足为永久错误，其余（网络、超时等）可重试
+func IsTemporary(err error) bool {
+	if err == nil {
+		return false
+	}
+	var we *wechat.Error
+	if errors.As(err, &we) {
+		return we.Temporary()
+	}
+	var ae *alipay.Error
+	if errors.As(err, &ae) {
+		return ae.Temporary()
+	}
+	var de *douyin.Error
+	if errors.As(err, &de) {
+		return de.Temporary()
+	}
+	return !errors.Is(err, ErrNoChannel) && !errors.Is(err, quota.ErrNoQuota)
+}
+
+// QuotaGuard 发送时扣减用户的订阅次数
+// 平台返回用户未订阅时清空次数，可重试的失败退还次数
+type QuotaGuard struct {
+	// Next 实际的投递方式
+	Next Deliverer
+}
+
+// Deliver 扣减订阅次数后发送
+func (g *QuotaGuard) Deliver(m *Model) (string, error) {
+	q := m.quota()
+	if err := q.Consume(m.Channel, m.Receiver, m.TemplateID); err != nil {
+		return "", err
+	}
+	msgID, err := g.Next.Deliver(m)
+	switch {
+	case err == nil:
+	case errors.Is(err, wechat.ErrUserRefused):
+		_ = q.Exhaust(m.Channel, m.Receiver, m.TemplateID)
+	case IsTemporary(err):
+		_ = q.Refund(m.Channel, m.Receiver, m.TemplateID)
+	}
+	return msgID, err
+}
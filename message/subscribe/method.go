@@ -1 +1,267 @@
 package subscribe
+
+import (
+	"errors"
+	"time"
+
+	"github.com/r2day/m3s/message/quota"
+	"github.com/r2day/m3s/message/ratelimit"
+	"github.com/r2day/m3s/message/status"
+	"github.com/r2day/m3s/message/stpl"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxEnqueueAttempts 幂等入队因并发冲突重试的次数
+	maxEnqueueAttempts = 3
+	// defaultDedupWindow 默认的幂等去重时间窗口
+	defaultDedupWindow = 24 * time.Hour
+)
+
+var (
+	// ErrExpired 消息已过期
+	ErrExpired = errors.New("subscribe message expired")
+	// ErrEnqueueConflict 幂等入队持续冲突
+	ErrEnqueueConflict = errors.New("subscribe message enqueue conflict")
+)
+
+// Validate 按登记的模版定义校验并规整消息主体
+// 超长的 thing 会被自动截断
+func (m *Model) Validate() error {
+	tpl := &stpl.Model{}
+	tpl.Init(m.Context.Context, m.Context.Handler, tpl.CollectionName())
+	payload, err := tpl.ValidatePayload(m.TemplateID, m.Payload)
+	if err != nil {
+		return err
+	}
+	m.Payload = payload
+	return nil
+}
+
+// EnqueueOptions 入队选项
+type EnqueueOptions struct {
+	// DedupWindow 幂等去重的时间窗口
+	// 同一幂等键在窗口内重复入队返回已有消息，超过窗口后可再次入队；为 0 时使用 24 小时，小于 0 时永久去重
+	DedupWindow time.Duration
+	// RateLimits 发送频率限制，为空时不限制
+	// 例如：[]ratelimit.Rule{{Scope: ratelimit.PerReceiver, Max: 10, Window: 3600}}
+	RateLimits []ratelimit.Rule
+}
+
+// rateLimits 发送频率限制
+func (o *EnqueueOptions) rateLimits() []ratelimit.Rule {
+	if o == nil {
+		return nil
+	}
+	return o.RateLimits
+}
+
+// dedupWindow 幂等去重的时间窗口，小于 0 表示永久去重
+func (o *EnqueueOptions) dedupWindow() time.Duration {
+	if o == nil || o.DedupWindow == 0 {
+		return defaultDedupWindow
+	}
+	return o.DedupWindow
+}
+
+// Enqueue 使用默认选项入队，见 EnqueueWith
+func (m *Model) Enqueue() (string, error) {
+	return m.EnqueueWith(nil)
+}
+
+// EnqueueWith 校验消息并写入发件箱，由 Worker 负责投递，opt 为空时使用默认选项
+// 设置了幂等键且去重窗口内已有相同消息时，不再重复创建，返回已有消息（m 会被替换为已有消息）
+// 用户没有该模版的剩余订阅次数时返回 quota.ErrNoQuota
+func (m *Model) EnqueueWith(opt *EnqueueOptions) (string, error) {
+	if m.IdempotencyKey == "" {
+		return m.enqueue(opt)
+	}
+	for attempt := 0; attempt < maxEnqueueAttempts; attempt++ {
+		existing, err := m.findByIdempotencyKey(m.IdempotencyKey, time.Now(), opt.dedupWindow())
+		if err == nil {
+			ctx := m.Context
+			*m = *existing
+			m.Context = ctx
+			return existing.ID.Hex(), nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return "", err
+		}
+		id, err := m.enqueue(opt)
+		if mongo.IsDuplicateKeyError(err) {
+			// 并发入队，由唯一索引保证只有一条成功
+			continue
+		}
+		return id, err
+	}
+	return "", ErrEnqueueConflict
+}
+
+// findByIdempotencyKey 查找去重窗口内的相同消息
+// 超过窗口的旧消息会释放幂等键，以便重新入队
+func (m *Model) findByIdempotencyKey(key string, now time.Time, window time.Duration) (*Model, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	existing := &Model{}
+	err := coll.FindOne(m.Context.Context, bson.D{{Key: "idempotency_key", Value: key}}).Decode(existing)
+	if err != nil {
+		return nil, err
+	}
+	if window < 0 || existing.StatusTimes[status.Queued] > now.Add(-window).Unix() {
+		return existing, nil
+	}
+	_, err = coll.UpdateOne(m.Context.Context,
+		bson.D{{Key: "_id", Value: existing.ID}, {Key: "idempotency_key", Value: key}},
+		bson.D{
+			{Key: "$unset", Value: bson.D{{Key: "idempotency_key", Value: ""}}},
+			{Key: "$set", Value: bson.D{{Key: "released_idempotency_key", Value: key}}},
+		})
+	if err != nil {
+		return nil, err
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+// enqueue 校验并写入新消息
+func (m *Model) enqueue(opt *EnqueueOptions) (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+	// 预检查剩余次数，发送时由 Consume 原子扣减
+	if err := m.quota().Check(m.Channel, m.Receiver, m.TemplateID); err != nil {
+		return "", err
+	}
+	if m.Operator == "" {
+		m.Operator = "system"
+	}
+	now := time.Now()
+	if m.ExpireTime > 0 && m.ExpireTime <= now.Unix() {
+		return "", ErrExpired
+	}
+	m.Status = status.Queued
+	m.StatusTimes = status.Timeline{status.Queued: now.Unix()}
+	m.Attempts = 0
+	m.NextAttemptTime = now.Unix()
+	if m.NotBefore > m.NextAttemptTime {
+		m.NextAttemptTime = m.NotBefore
+	}
+
+	// 超出频率限制的消息标记为已抑制并保留记录，不会被投递
+	limiter := &ratelimit.Model{}
+	limiter.Init(m.Context.Context, m.Context.Handler, limiter.CollectionName())
+	rule, err := limiter.Allow(modelName, opt.rateLimits(), ratelimit.Subject{Receiver: m.Receiver, Template: m.TemplateID})
+	if err != nil {
+		return "", err
+	}
+	if rule != nil {
+		m.Status = status.Suppressed
+		m.StatusTimes[status.Suppressed] = now.Unix()
+		m.Error = "rate limited: " + rule.String()
+	}
+	return m.Create(m)
+}
+
+// CancelByKey 取消关联编号下尚未发送的消息，返回取消的数量
+// 已被投递实例领取（发送中）的消息无法取消
+func (m *Model) CancelByKey(key string) (int64, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{
+		{Key: "correlation_key", Value: key},
+		{Key: status.Field, Value: status.Queued},
+	}
+	update := bson.D{{Key: "$set", Value: status.SetFields(status.Cancelled, time.Now())}}
+	result, err := coll.UpdateMany(m.Context.Context, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// ExpireDue 将已过期且尚未发送的消息标记为过期，返回数量
+func (m *Model) ExpireDue(now time.Time) (int64, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{
+		{Key: status.Field, Value: status.Queued},
+		{Key: "expire_time", Value: bson.D{{Key: "$gt", Value: 0}, {Key: "$lte", Value: now.Unix()}}},
+	}
+	update := bson.D{{Key: "$set", Value: status.SetFields(status.Expired, now)}}
+	result, err := coll.UpdateMany(m.Context.Context, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// UpdateStatus 原子更新消息状态：仅当当前状态为 from 时更新为 to
+// set 为需要同时写入的其他字段
+func (m *Model) UpdateStatus(from, to status.Status, set ...bson.E) error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	filter := bson.D{{Key: "_id", Value: m.ID}}
+	if err := status.Update(m.Context.Context, coll, filter, from, to, set); err != nil {
+		return err
+	}
+	m.Status = to
+	if m.StatusTimes == nil {
+		m.StatusTimes = status.Timeline{}
+	}
+	m.StatusTimes[to] = time.Now().Unix()
+	return nil
+}
+
+// MigrateLegacyStatus 将旧版本的整数状态迁移为字符串状态
+func (m *Model) MigrateLegacyStatus() (int64, error) {
+	return status.MigrateLegacy(m.Context.Context, m.Context.Handler.Collection(m.Context.Collection))
+}
+
+// CountByStatus 统计各状态的消息数量
+func (m *Model) CountByStatus() (map[status.Status]int64, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	pipeline := bson.A{
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$message_status"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+	cursor, err := coll.Aggregate(m.Context.Context, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]struct {
+		Status status.Status `bson:"_id"`
+		Count  int64         `bson:"count"`
+	}, 0)
+	if err = cursor.All(m.Context.Context, &rows); err != nil {
+		return nil, err
+	}
+	results := make(map[status.Status]int64, len(rows))
+	for _, row := range rows {
+		results[row.Status] += row.Count
+	}
+	return results, nil
+}
+
+// quota 订阅额度
+func (m *Model) quota() *quota.Model {
+	q := &quota.Model{}
+	q.Init(m.Context.Context, m.Context.Handler, q.CollectionName())
+	return q
+}
+
+// CreateIndexes 创建发件箱需要的索引
+// 幂等键唯一（仅对设置了幂等键的消息生效），以及投递与查询使用的索引
+func (m *Model) CreateIndexes() error {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	_, err := coll.Indexes().CreateMany(m.Context.Context, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "idempotency_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(
+				bson.D{{Key: "idempotency_key", Value: bson.D{{Key: "$exists", Value: true}}}}),
+		},
+		{Keys: bson.D{{Key: status.Field, Value: 1}, {Key: "next_attempt_time", Value: 1}}},
+		{Keys: bson.D{{Key: "correlation_key", Value: 1}}},
+		{Keys: bson.D{{Key: "receiver", Value: 1}, {Key: queuedTimeField, Value: -1}}},
+	})
+	return err
+}
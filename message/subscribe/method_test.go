@@ -0,0 +1,24 @@
+package subscribe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnqueueOptionsDedupWindow(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  *EnqueueOptions
+		want time.Duration
+	}{
+		{"nil", nil, defaultDedupWindow},
+		{"zero", &EnqueueOptions{}, defaultDedupWindow},
+		{"custom", &EnqueueOptions{DedupWindow: time.Hour}, time.Hour},
+		{"permanent", &EnqueueOptions{DedupWindow: -1}, -1},
+	}
+	for _, tt := range tests {
+		if got := tt.opt.dedupWindow(); got != tt.want {
+			t.Errorf("%s: dedupWindow = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
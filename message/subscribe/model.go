@@ -2,6 +2,8 @@ package subscribe
 
 import (
 	"github.com/open4go/model"
+	home "github.com/r2day/m3s/client/home"
+	"github.com/r2day/m3s/message/status"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -17,19 +19,15 @@ const (
 	modelName = "subscribe"
 )
 
-// MessageStatus represents the status of the message
-type MessageStatus int
+// MessageStatus 消息状态
+type MessageStatus = status.Status
 
+// Deprecated: 使用 status 包中的状态。
+// 旧版本的 Unread 与 Sent 含义相同（已发送未读），已移除，请使用 status.Sent
 const (
-	// Sent represents a message status of sent
-	Sent MessageStatus = iota
-	// Read represents a message status of read
-	Read
-	// Unread represents a message status of unread
-	Unread
-	// Deleted represents a message status of deleted
-	Deleted
-	// Add more statuses as needed
+	Sent    = status.Sent
+	Read    = status.Read
+	Deleted = status.Deleted
 )
 
 // MessageType represents the type of the message
@@ -47,14 +45,49 @@ type Model struct {
 	Operator string `json:"operator" bson:"operator,omitempty"`
 	// 模版
 	TemplateID string `json:"template_id" bson:"template_id,omitempty"`
-	// 接受者
+	// Channel 消息渠道：微信/支付宝/抖音小程序（默认微信）
+	Channel home.AppType `json:"channel" bson:"channel,omitempty"`
+	// 接受者（对应渠道的 openid/用户号）
 	Receiver string `json:"receiver" bson:"receiver,omitempty"`
-	// 消息主体
-	Payload MessagePayload `json:"payload" bson:"payload,omitempty"`
+	// 消息主体（关键词编号 -> 内容，按模版定义校验）
+	Payload Payload `json:"payload" bson:"payload,omitempty"`
 	// 消息状态
-	Status MessageStatus `json:"message_status" bson:"message_status,omitempty"`
+	Status status.Status `json:"message_status" bson:"message_status,omitempty"`
+	// StatusTimes 各状态的变更时间
+	StatusTimes status.Timeline `json:"status_times" bson:"status_times,omitempty"`
+	// Page 点击消息跳转的小程序页面
+	Page string `json:"page" bson:"page,omitempty"`
+	// MsgID 平台返回的消息编号
+	MsgID string `json:"msg_id" bson:"msg_id,omitempty"`
+	// Error 最近一次发送失败的原因
+	Error string `json:"error" bson:"error,omitempty"`
+	// SentTime 发送时间
+	SentTime int64 `json:"sent_time" bson:"sent_time,omitempty"`
+	// NotBefore 最早发送时间（为空时立即发送），例如：取餐前10分钟提醒
+	NotBefore int64 `json:"not_before" bson:"not_before,omitempty"`
+	// ExpireTime 过期时间，超过后未发送的消息不再发送（为空时不过期）
+	ExpireTime int64 `json:"expire_time" bson:"expire_time,omitempty"`
+	// IdempotencyKey 幂等键（例如：订单号+通知类型），相同键在去重窗口内只会入队一次
+	IdempotencyKey string `json:"idempotency_key" bson:"idempotency_key,omitempty"`
+	// CorrelationKey 业务关联编号（例如订单号），用于批量取消
+	CorrelationKey string `json:"correlation_key" bson:"correlation_key,omitempty"`
+	// Attempts 已尝试发送次数
+	Attempts int `json:"attempts" bson:"attempts,omitempty"`
+	// NextAttemptTime 下次尝试发送的时间
+	NextAttemptTime int64 `json:"next_attempt_time" bson:"next_attempt_time,omitempty"`
+	// LeaseOwner 当前持有发送租约的实例
+	LeaseOwner string `json:"lease_owner" bson:"lease_owner,omitempty"`
+	// LeaseExpireTime 租约到期时间，到期后其他实例可重新领取
+	LeaseExpireTime int64 `json:"lease_expire_time" bson:"lease_expire_time,omitempty"`
 }
 
+// Payload 消息主体，关键词编号与内容的对应关系
+// 例如: {"thing5": "生椰拿铁", "amount12": "19.50元"}
+type Payload map[string]string
+
+// MessagePayload 取餐提醒模版的消息主体
+//
+// Deprecated: 使用 Payload 以适配任意模版
 type MessagePayload struct {
 	Thing5   string `json:"thing5" bson:"thing5"`
 	Amount12 string `json:"amount12" bson:"amount12"`
@@ -62,6 +95,16 @@ type MessagePayload struct {
 	Date4    string `json:"date4" bson:"date4"`
 }
 
+// ToPayload 转换为通用的消息主体
+func (p MessagePayload) ToPayload() Payload {
+	return Payload{
+		"thing5":   p.Thing5,
+		"amount12": p.Amount12,
+		"thing7":   p.Thing7,
+		"date4":    p.Date4,
+	}
+}
+
 // ResourceName 返回资源名称
 func (m *Model) ResourceName() string {
 	//m.Meta = m.GetMeta()
@@ -0,0 +1,241 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/open4go/log"
+	"github.com/r2day/m3s/message/status"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultLease 默认发送租约时长
+	defaultLease = time.Minute
+	// defaultBatchSize 每轮最多领取的消息数
+	defaultBatchSize = 50
+	// defaultMaxAttempts 默认最大尝试次数，超过后进入死信
+	defaultMaxAttempts = 5
+	// defaultBaseBackoff 首次重试间隔
+	defaultBaseBackoff = 10 * time.Second
+	// defaultMaxBackoff 最大重试间隔
+	defaultMaxBackoff = 30 * time.Minute
+	// defaultPollInterval 发件箱为空时的轮询间隔
+	defaultPollInterval = 2 * time.Second
+)
+
+// Deliverer 消息投递
+type Deliverer interface {
+	// Deliver 发送消息，返回平台消息编号
+	Deliver(m *Model) (string, error)
+}
+
+// Worker 订阅消息发件箱投递
+// 通过租约领取待发送消息，多实例部署时同一消息只会被一个实例发送
+// 永久失败的消息标记为失败状态（死信），并记录最后一次错误
+type Worker struct {
+	// DB 数据库
+	DB *mongo.Database
+	// Deliverer 消息投递
+	Deliverer Deliverer
+	// Owner 实例标识，为空时使用主机名与进程号
+	Owner string
+	// Lease 租约时长，需大于单条消息的发送耗时
+	Lease time.Duration
+	// BatchSize 每轮最多领取的消息数
+	BatchSize int
+	// MaxAttempts 最大尝试次数
+	MaxAttempts int
+	// BaseBackoff 首次重试间隔，之后按指数退避
+	BaseBackoff time.Duration
+	// MaxBackoff 最大重试间隔
+	MaxBackoff time.Duration
+	// PollInterval 发件箱为空时的轮询间隔
+	PollInterval time.Duration
+	// IsTemporary 判断错误是否可重试，为空时使用 IsTemporary
+	IsTemporary func(err error) bool
+}
+
+// NewWorker 创建发件箱投递
+func NewWorker(db *mongo.Database, d Deliverer) *Worker {
+	host, _ := os.Hostname()
+	return &Worker{
+		DB:           db,
+		Deliverer:    d,
+		Owner:        fmt.Sprintf("%s-%d", host, os.Getpid()),
+		Lease:        defaultLease,
+		BatchSize:    defaultBatchSize,
+		MaxAttempts:  defaultMaxAttempts,
+		BaseBackoff:  defaultBaseBackoff,
+		MaxBackoff:   defaultMaxBackoff,
+		PollInterval: defaultPollInterval,
+		IsTemporary:  IsTemporary,
+	}
+}
+
+// Run 持续投递直到 ctx 结束
+func (w *Worker) Run(ctx context.Context) error {
+	for {
+		n, err := w.RunOnce(ctx)
+		if err != nil {
+			log.Log(ctx).WithField("owner", w.Owner).Error(err)
+		}
+		if n > 0 && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+// RunOnce 领取并投递一批到期的消息，返回处理的数量
+// 只投递已到发送时间的消息，过期未发送的消息直接标记为过期
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	expirer := &Model{}
+	expirer.Init(ctx, w.DB, expirer.CollectionName())
+	if _, err := expirer.ExpireDue(time.Now()); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for count < w.BatchSize {
+		m, err := w.claim(ctx)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+		if err = w.deliver(ctx, m); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// collection 发件箱表
+func (w *Worker) collection() *mongo.Collection {
+	return w.DB.Collection((&Model{}).CollectionName())
+}
+
+// claim 领取一条到期的待发送消息，或租约已过期的发送中消息
+func (w *Worker) claim(ctx context.Context) (*Model, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "$or", Value: bson.A{
+			bson.D{
+				{Key: status.Field, Value: status.Queued},
+				{Key: "next_attempt_time", Value: bson.D{{Key: "$lte", Value: now.Unix()}}},
+			},
+			bson.D{
+				{Key: status.Field, Value: status.Sending},
+				{Key: "lease_expire_time", Value: bson.D{{Key: "$lt", Value: now.Unix()}}},
+			},
+		}},
+	}
+	set := append(status.SetFields(status.Sending, now),
+		bson.E{Key: "lease_owner", Value: w.Owner},
+		bson.E{Key: "lease_expire_time", Value: now.Add(w.Lease).Unix()})
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	opt := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_time", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	m := &Model{}
+	if err := w.collection().FindOneAndUpdate(ctx, filter, update, opt).Decode(m); err != nil {
+		return nil, err
+	}
+	m.Init(ctx, w.DB, m.CollectionName())
+	return m, nil
+}
+
+// deliver 投递消息并根据结果更新状态
+// 发送成功为已发送，可重试的错误重新入队，其余进入死信（失败状态）
+// 只有仍持有租约时才会更新，避免租约过期后覆盖其他实例的结果
+func (w *Worker) deliver(ctx context.Context, m *Model) error {
+	filter := bson.D{{Key: "_id", Value: m.ID}, {Key: "lease_owner", Value: w.Owner}}
+	if m.ExpireTime > 0 && m.ExpireTime <= time.Now().Unix() {
+		// 重试期间已过期，不再发送
+		return w.release(ctx, filter, status.Expired, nil)
+	}
+
+	msgID, sendErr := w.Deliverer.Deliver(m)
+	now := time.Now()
+
+	to := status.Sent
+	set := bson.D{}
+	switch {
+	case sendErr == nil:
+		set = append(set,
+			bson.E{Key: "msg_id", Value: msgID},
+			bson.E{Key: "error", Value: ""},
+			bson.E{Key: "sent_time", Value: now.Unix()})
+	case w.temporary(sendErr) && m.Attempts < w.MaxAttempts:
+		to = status.Queued
+		set = append(set,
+			bson.E{Key: "error", Value: sendErr.Error()},
+			bson.E{Key: "next_attempt_time", Value: now.Add(w.backoff(m.Attempts)).Unix()})
+	default:
+		to = status.Failed
+		set = append(set, bson.E{Key: "error", Value: sendErr.Error()})
+	}
+
+	if err := w.release(ctx, filter, to, set); err != nil {
+		return err
+	}
+	if sendErr != nil {
+		log.Log(ctx).WithField("id", m.ID.Hex()).
+			WithField("attempts", m.Attempts).Warning(sendErr)
+	}
+	return nil
+}
+
+// release 更新发送结果并释放租约
+func (w *Worker) release(ctx context.Context, filter bson.D, to status.Status, set bson.D) error {
+	set = append(set,
+		bson.E{Key: "lease_owner", Value: ""},
+		bson.E{Key: "lease_expire_time", Value: int64(0)})
+	err := status.Update(ctx, w.collection(), filter, status.Sending, to, set)
+	if errors.Is(err, status.ErrConflict) {
+		// 租约已过期并被其他实例领取
+		log.Log(ctx).WithField("filter", filter).Warning("subscribe message lease lost")
+		return nil
+	}
+	return err
+}
+
+// temporary 判断错误是否可重试
+func (w *Worker) temporary(err error) bool {
+	if w.IsTemporary == nil {
+		return IsTemporary(err)
+	}
+	return w.IsTemporary(err)
+}
+
+// backoff 第 attempts 次失败后的重试间隔（指数退避并附加随机抖动）
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.BaseBackoff
+	for i := 1; i < attempts && d < w.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.MaxBackoff {
+		d = w.MaxBackoff
+	}
+	if d > 0 {
+		d += time.Duration(rand.Int63n(int64(d)/5 + 1))
+	}
+	return d
+}
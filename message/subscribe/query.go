@@ -0,0 +1,176 @@
+package subscribe
+
+import (
+	"encoding/binary"
+
+	home "github.com/r2day/m3s/client/home"
+	"github.com/r2day/m3s/message/status"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultPageSize 默认每页数量
+	defaultPageSize = 20
+	// maxPageSize 每页最大数量
+	maxPageSize = 100
+	// queuedTimeField 入队时间字段
+	queuedTimeField = status.TimelineField + "." + string(status.Queued)
+)
+
+// Query 消息查询条件，空值表示不限制
+type Query struct {
+	// Receiver 接收者
+	Receiver string `json:"receiver" form:"receiver"`
+	// TemplateID 模版编号
+	TemplateID string `json:"template_id" form:"template_id"`
+	// Operator 操作人
+	Operator string `json:"operator" form:"operator"`
+	// CorrelationKey 业务关联编号（例如订单号）
+	CorrelationKey string `json:"correlation_key" form:"correlation_key"`
+	// Channel 消息渠道
+	Channel *home.AppType `json:"channel" form:"channel"`
+	// Status 消息状态（任一）
+	Status []status.Status `json:"status" form:"status"`
+	// StartTime 入队时间起（含）
+	StartTime int64 `json:"start_time" form:"start_time"`
+	// EndTime 入队时间止（不含）
+	EndTime int64 `json:"end_time" form:"end_time"`
+	// Page 页码，从1开始
+	Page int64 `json:"page" form:"page"`
+	// PageSize 每页数量
+	PageSize int64 `json:"page_size" form:"page_size"`
+}
+
+// StatusCount 模版各状态的消息数量
+type StatusCount struct {
+	// TemplateID 模版编号
+	TemplateID string `json:"template_id" bson:"template_id"`
+	// Status 消息状态
+	Status status.Status `json:"status" bson:"status"`
+	// Count 数量
+	Count int64 `json:"count" bson:"count"`
+}
+
+// Filter 转换为数据库查询条件
+func (q *Query) Filter() bson.D {
+	filter := bson.D{}
+	if q.Receiver != "" {
+		filter = append(filter, bson.E{Key: "receiver", Value: q.Receiver})
+	}
+	if q.TemplateID != "" {
+		filter = append(filter, bson.E{Key: "template_id", Value: q.TemplateID})
+	}
+	if q.Operator != "" {
+		filter = append(filter, bson.E{Key: "operator", Value: q.Operator})
+	}
+	if q.CorrelationKey != "" {
+		filter = append(filter, bson.E{Key: "correlation_key", Value: q.CorrelationKey})
+	}
+	if q.Channel != nil {
+		if *q.Channel == home.WxApp {
+			filter = append(filter, bson.E{Key: "channel", Value: bson.D{{Key: "$in", Value: bson.A{nil, home.WxApp}}}})
+		} else {
+			filter = append(filter, bson.E{Key: "channel", Value: *q.Channel})
+		}
+	}
+	if len(q.Status) > 0 {
+		filter = append(filter, bson.E{Key: status.Field, Value: bson.D{{Key: "$in", Value: q.Status}}})
+	}
+	if q.StartTime > 0 || q.EndTime > 0 {
+		// 早期的消息没有状态时间线，按编号中的创建时间筛选
+		queued, created := bson.D{}, bson.D{}
+		if q.StartTime > 0 {
+			queued = append(queued, bson.E{Key: "$gte", Value: q.StartTime})
+			created = append(created, bson.E{Key: "$gte", Value: objectIDAt(q.StartTime)})
+		}
+		if q.EndTime > 0 {
+			queued = append(queued, bson.E{Key: "$lt", Value: q.EndTime})
+			created = append(created, bson.E{Key: "$lt", Value: objectIDAt(q.EndTime)})
+		}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: queuedTimeField, Value: queued}},
+			bson.D{
+				{Key: queuedTimeField, Value: bson.D{{Key: "$exists", Value: false}}},
+				{Key: "_id", Value: created},
+			},
+		}})
+	}
+	return filter
+}
+
+// objectIDAt 创建时间为 t 的最小编号，用于按创建时间筛选
+func objectIDAt(t int64) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[0:4], uint32(t))
+	return id
+}
+
+// pagination 规整分页参数
+func (q *Query) pagination() (skip int64, limit int64) {
+	limit = q.PageSize
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	page := q.Page
+	if page <= 0 {
+		page = 1
+	}
+	return (page - 1) * limit, limit
+}
+
+// Search 分页查询消息（按入队时间倒序），返回当前页与总数
+// 消息入队时创建，编号中的创建时间即入队时间，按编号排序同样适用于没有状态时间线的早期消息
+func (m *Model) Search(q *Query) ([]*Model, int64, error) {
+	results := make([]*Model, 0)
+	skip, limit := q.pagination()
+	opt := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	total, err := m.GetListWithOpt(q.Filter(), &results, opt)
+	if err != nil {
+		return nil, total, err
+	}
+	return results, total, nil
+}
+
+// GetByReceiver 查询用户收到的消息（例如：客服确认顾客是否收到取餐通知）
+func (m *Model) GetByReceiver(receiver string, page, pageSize int64) ([]*Model, int64, error) {
+	return m.Search(&Query{Receiver: receiver, Page: page, PageSize: pageSize})
+}
+
+// CountByTemplate 统计满足条件的消息在各模版、各状态下的数量
+func (m *Model) CountByTemplate(q *Query) ([]*StatusCount, error) {
+	coll := m.Context.Handler.Collection(m.Context.Collection)
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: q.Filter()}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "template_id", Value: "$template_id"},
+				{Key: "status", Value: "$" + status.Field},
+			}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "template_id", Value: "$_id.template_id"},
+			{Key: "status", Value: "$_id.status"},
+			{Key: "count", Value: 1},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "template_id", Value: 1}, {Key: "status", Value: 1}}}},
+	}
+	cursor, err := coll.Aggregate(m.Context.Context, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*StatusCount, 0)
+	if err = cursor.All(m.Context.Context, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
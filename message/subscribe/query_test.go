@@ -0,0 +1,77 @@
+package subscribe
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	home "github.com/r2day/m3s/client/home"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestQueryFilterTime(t *testing.T) {
+	start, end := int64(1700000000), int64(1700086400)
+	q := &Query{StartTime: start, EndTime: end}
+	want := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: queuedTimeField, Value: bson.D{{Key: "$gte", Value: start}, {Key: "$lt", Value: end}}}},
+		bson.D{
+			{Key: queuedTimeField, Value: bson.D{{Key: "$exists", Value: false}}},
+			{Key: "_id", Value: bson.D{
+				{Key: "$gte", Value: objectIDAt(start)},
+				{Key: "$lt", Value: objectIDAt(end)},
+			}},
+		},
+	}}}
+	if got := q.Filter(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Filter() = %v, want %v", got, want)
+	}
+}
+
+func TestObjectIDAt(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	id := objectIDAt(at.Unix())
+	if !id.Timestamp().Equal(at) {
+		t.Fatalf("Timestamp() = %s, want %s", id.Timestamp(), at)
+	}
+	// 同一秒生成的编号都不小于该编号
+	if later := primitive.NewObjectIDFromTimestamp(at); later.Hex() < id.Hex() {
+		t.Fatalf("%s < %s", later.Hex(), id.Hex())
+	}
+}
+
+func TestQueryFilterChannel(t *testing.T) {
+	wx, alipay := home.WxApp, home.AlipayApp
+	tests := []struct {
+		name    string
+		channel *home.AppType
+		want    bson.D
+	}{
+		{"any", nil, bson.D{}},
+		{"wechat", &wx, bson.D{{Key: "channel", Value: bson.D{{Key: "$in", Value: bson.A{nil, home.WxApp}}}}}},
+		{"alipay", &alipay, bson.D{{Key: "channel", Value: home.AlipayApp}}},
+	}
+	for _, tt := range tests {
+		if got := (&Query{Channel: tt.channel}).Filter(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Filter() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryPagination(t *testing.T) {
+	tests := []struct {
+		page, size      int64
+		skip, wantLimit int64
+	}{
+		{0, 0, 0, defaultPageSize},
+		{2, 10, 10, 10},
+		{3, 1000, 2 * maxPageSize, maxPageSize},
+		{-1, -1, 0, defaultPageSize},
+	}
+	for _, tt := range tests {
+		skip, limit := (&Query{Page: tt.page, PageSize: tt.size}).pagination()
+		if skip != tt.skip || limit != tt.wantLimit {
+			t.Errorf("pagination(%d, %d) = %d, %d, want %d, %d", tt.page, tt.size, skip, limit, tt.skip, tt.wantLimit)
+		}
+	}
+}
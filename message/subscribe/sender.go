@@ -0,0 +1,80 @@
+package subscribe
+
+import (
+	"strconv"
+	"time"
+
+	home "github.com/r2day/m3s/client/home"
+	"github.com/r2day/m3s/message/status"
+	"github.com/r2day/m3s/message/wechat"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Sender 微信订阅消息发送
+type Sender struct {
+	// Client 小程序接口客户端
+	Client *wechat.Client
+}
+
+// NewSender 创建订阅消息发送
+func NewSender(client *wechat.Client) *Sender {
+	return &Sender{Client: client}
+}
+
+// Deliver 调用微信接口发送订阅消息，不更新消息状态
+func (s *Sender) Deliver(m *Model) (string, error) {
+	data := make(map[string]wechat.DataItem, len(m.Payload))
+	for k, v := range m.Payload {
+		data[k] = wechat.DataItem{Value: v}
+	}
+	msgID, err := s.Client.SendSubscribeMessage(m.Context.Context, &wechat.SubscribeMessage{
+		ToUser:     m.Receiver,
+		TemplateID: m.TemplateID,
+		Page:       m.Page,
+		Data:       data,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(msgID, 10), nil
+}
+
+// Channel 渠道类型
+func (s *Sender) Channel() home.AppType {
+	return home.WxApp
+}
+
+// Send 发送订阅消息并更新消息状态
+// 消息需处于待发送状态，发送失败时记录错误原因并返回微信错误（可使用 errors.Is 判断错误类型）
+func (s *Sender) Send(m *Model) error {
+	return send(s, m, m)
+}
+
+// statusUpdater 消息状态更新
+type statusUpdater interface {
+	UpdateStatus(from, to status.Status, set ...bson.E) error
+}
+
+// send 使用指定的投递方式发送消息并通过 u 更新消息状态
+func send(d Deliverer, u statusUpdater, m *Model) error {
+	if err := u.UpdateStatus(m.Status, status.Sending); err != nil {
+		return err
+	}
+	msgID, err := d.Deliver(m)
+	if err != nil {
+		m.Error = err.Error()
+		if uerr := u.UpdateStatus(status.Sending, status.Failed,
+			bson.E{Key: "error", Value: m.Error}); uerr != nil {
+			return uerr
+		}
+		return err
+	}
+
+	m.MsgID = msgID
+	m.Error = ""
+	m.SentTime = time.Now().Unix()
+	return u.UpdateStatus(status.Sending, status.Sent,
+		bson.E{Key: "msg_id", Value: m.MsgID},
+		bson.E{Key: "error", Value: m.Error},
+		bson.E{Key: "sent_time", Value: m.SentTime})
+}
@@ -0,0 +1,147 @@
+package subscribe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/r2day/m3s/message/status"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fakeUpdater 记录状态变更，不访问数据库
+type fakeUpdater struct {
+	m     *Model
+	steps []string
+	sets  []bson.E
+	err   map[status.Status]error
+}
+
+func (u *fakeUpdater) UpdateStatus(from, to status.Status, set ...bson.E) error {
+	if err := status.Transition(from, to); err != nil {
+		return err
+	}
+	if err := u.err[to]; err != nil {
+		return err
+	}
+	u.steps = append(u.steps, string(from)+"->"+string(to))
+	u.sets = append(u.sets, set...)
+	u.m.Status = to
+	return nil
+}
+
+// fakeDeliverer 返回固定的发送结果
+type fakeDeliverer struct {
+	msgID string
+	err   error
+}
+
+func (d *fakeDeliverer) Deliver(m *Model) (string, error) {
+	return d.msgID, d.err
+}
+
+func TestSendStatusTransitions(t *testing.T) {
+	errRefused := errors.New("refused")
+	errDB := errors.New("db down")
+	tests := []struct {
+		name      string
+		from      status.Status
+		deliver   *fakeDeliverer
+		updateErr map[status.Status]error
+		wantErr   error
+		wantSteps []string
+		want      status.Status
+	}{
+		{
+			name:      "sent",
+			from:      status.Queued,
+			deliver:   &fakeDeliverer{msgID: "42"},
+			wantSteps: []string{"queued->sending", "sending->sent"},
+			want:      status.Sent,
+		},
+		{
+			name:      "failed",
+			from:      status.Queued,
+			deliver:   &fakeDeliverer{err: errRefused},
+			wantErr:   errRefused,
+			wantSteps: []string{"queued->sending", "sending->failed"},
+			want:      status.Failed,
+		},
+		{
+			name:      "resend in progress",
+			from:      status.Sending,
+			deliver:   &fakeDeliverer{msgID: "42"},
+			wantSteps: []string{"sending->sending", "sending->sent"},
+			want:      status.Sent,
+		},
+		{
+			name:    "illegal",
+			from:    status.Read,
+			deliver: &fakeDeliverer{msgID: "42"},
+			wantErr: status.ErrIllegalTransition,
+			want:    status.Read,
+		},
+		{
+			name:      "claim conflict",
+			from:      status.Queued,
+			deliver:   &fakeDeliverer{msgID: "42"},
+			updateErr: map[status.Status]error{status.Sending: status.ErrConflict},
+			wantErr:   status.ErrConflict,
+			want:      status.Queued,
+		},
+		{
+			name:      "failure not recorded",
+			from:      status.Queued,
+			deliver:   &fakeDeliverer{err: errRefused},
+			updateErr: map[status.Status]error{status.Failed: errDB},
+			wantErr:   errDB,
+			wantSteps: []string{"queued->sending"},
+			want:      status.Sending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Model{Status: tt.from}
+			u := &fakeUpdater{m: m, err: tt.updateErr}
+			err := send(tt.deliver, u, m)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(u.steps) != len(tt.wantSteps) {
+				t.Fatalf("steps = %v, want %v", u.steps, tt.wantSteps)
+			}
+			for i := range u.steps {
+				if u.steps[i] != tt.wantSteps[i] {
+					t.Fatalf("steps = %v, want %v", u.steps, tt.wantSteps)
+				}
+			}
+			if m.Status != tt.want {
+				t.Errorf("status = %s, want %s", m.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendRecordsResult(t *testing.T) {
+	m := &Model{Status: status.Queued, Error: "previous"}
+	u := &fakeUpdater{m: m}
+	if err := send(&fakeDeliverer{msgID: "42"}, u, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.MsgID != "42" || m.Error != "" || m.SentTime == 0 {
+		t.Errorf("msg_id=%q error=%q sent_time=%d", m.MsgID, m.Error, m.SentTime)
+	}
+	fields := map[string]interface{}{}
+	for _, e := range u.sets {
+		fields[e.Key] = e.Value
+	}
+	if fields["msg_id"] != "42" || fields["error"] != "" || fields["sent_time"] != m.SentTime {
+		t.Errorf("persisted fields = %v", fields)
+	}
+
+	m = &Model{Status: status.Queued}
+	u = &fakeUpdater{m: m}
+	_ = send(&fakeDeliverer{err: errors.New("refused")}, u, m)
+	if len(u.sets) != 1 || u.sets[0].Key != "error" || u.sets[0].Value != "refused" {
+		t.Errorf("failure fields = %v", u.sets)
+	}
+}
@@ -0,0 +1,190 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBaseURL 微信接口地址
+	defaultBaseURL = "https://api.weixin.qq.com"
+	// refreshBefore 提前刷新 access_token 的时间，避免临界过期
+	refreshBefore = 5 * time.Minute
+	// defaultTimeout 接口调用超时时间
+	defaultTimeout = 10 * time.Second
+)
+
+// Client 小程序服务端接口
+// access_token 缓存在内存中，过期前自动刷新，并发调用只会触发一次刷新
+type Client struct {
+	// AppID 小程序 appid
+	AppID string
+	// Secret 小程序 secret
+	Secret string
+	// BaseURL 接口地址，为空时使用微信正式地址（测试时可指向本地服务）
+	BaseURL string
+	// HTTPClient 为空时使用默认超时的 http.Client
+	HTTPClient *http.Client
+	// State 跳转小程序类型：developer 开发版、trial 体验版、formal 正式版
+	State string
+	// Lang 语言：zh_CN, en_US, zh_HK, zh_TW
+	Lang string
+
+	mu       sync.Mutex
+	token    string
+	expireAt time.Time
+}
+
+// NewClient 创建小程序接口客户端
+func NewClient(appID, secret string) *Client {
+	return &Client{
+		AppID:  appID,
+		Secret: secret,
+		State:  "formal",
+		Lang:   "zh_CN",
+	}
+}
+
+// DataItem 订阅消息关键词的值
+type DataItem struct {
+	Value string `json:"value"`
+}
+
+// SubscribeMessage 订阅消息
+type SubscribeMessage struct {
+	// ToUser 接收者 openid
+	ToUser string `json:"touser"`
+	// TemplateID 模版编号
+	TemplateID string `json:"template_id"`
+	// Page 点击消息跳转的页面
+	Page string `json:"page,omitempty"`
+	// Data 模版内容
+	Data map[string]DataItem `json:"data"`
+	// MiniProgramState 跳转小程序类型
+	MiniProgramState string `json:"miniprogram_state,omitempty"`
+	// Lang 语言
+	Lang string `json:"lang,omitempty"`
+}
+
+// tokenResponse 获取 access_token 的返回
+type tokenResponse struct {
+	Error
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// sendResponse 发送订阅消息的返回
+type sendResponse struct {
+	Error
+	MsgID int64 `json:"msgid"`
+}
+
+// AccessToken 获取 access_token，缓存有效时直接返回
+func (c *Client) AccessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 持有锁期间刷新，其他协程等待后直接使用刷新结果
+	if c.token != "" && time.Now().Add(refreshBefore).Before(c.expireAt) {
+		return c.token, nil
+	}
+
+	q := url.Values{}
+	q.Set("grant_type", "client_credential")
+	q.Set("appid", c.AppID)
+	q.Set("secret", c.Secret)
+	rsp := &tokenResponse{}
+	if err := c.do(ctx, http.MethodGet, "/cgi-bin/token?"+q.Encode(), nil, rsp); err != nil {
+		return "", err
+	}
+	if rsp.Code != 0 {
+		return "", &Error{Code: rsp.Code, Msg: rsp.Msg}
+	}
+	if rsp.AccessToken == "" {
+		return "", errors.New("wechat returned empty access token")
+	}
+	c.token = rsp.AccessToken
+	c.expireAt = time.Now().Add(time.Duration(rsp.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// InvalidateToken 清除缓存的 access_token
+// 仅当缓存的仍是失效的 token 时才清除，避免覆盖其他协程刚刷新的结果
+func (c *Client) InvalidateToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// SendSubscribeMessage 发送订阅消息，返回微信消息编号
+// access_token 失效时会刷新后重试一次
+func (c *Client) SendSubscribeMessage(ctx context.Context, msg *SubscribeMessage) (int64, error) {
+	if msg.MiniProgramState == "" {
+		msg.MiniProgramState = c.State
+	}
+	if msg.Lang == "" {
+		msg.Lang = c.Lang
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, err := c.AccessToken(ctx)
+		if err != nil {
+			return 0, err
+		}
+		rsp := &sendResponse{}
+		path := "/cgi-bin/message/subscribe/send?access_token=" + url.QueryEscape(token)
+		if err = c.do(ctx, http.MethodPost, path, body, rsp); err != nil {
+			return 0, err
+		}
+		if rsp.Code == 0 {
+			return rsp.MsgID, nil
+		}
+		werr := &Error{Code: rsp.Code, Msg: rsp.Msg}
+		if errors.Is(werr, ErrTokenInvalid) && attempt == 0 {
+			c.InvalidateToken(token)
+			continue
+		}
+		return 0, werr
+	}
+}
+
+// do 调用接口并解析 JSON 返回
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	base := c.BaseURL
+	if base == "" {
+		base = defaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: defaultTimeout}
+	}
+	rsp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("wechat http status %d", rsp.StatusCode)
+	}
+	return json.NewDecoder(rsp.Body).Decode(out)
+}
@@ -0,0 +1,237 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServer 模拟微信接口，sendCodes 依次作为发送接口的错误码返回
+type fakeServer struct {
+	tokens    int32
+	sends     int32
+	expiresIn int64
+	sendCodes []int
+	lastToken string
+	mu        sync.Mutex
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/cgi-bin/token":
+		n := atomic.AddInt32(&f.tokens, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token-" + strconv.Itoa(int(n)),
+			"expires_in":   f.expiresIn,
+		})
+	case "/cgi-bin/message/subscribe/send":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		n := int(atomic.AddInt32(&f.sends, 1))
+		f.lastToken = r.URL.Query().Get("access_token")
+		code := 0
+		if n <= len(f.sendCodes) {
+			code = f.sendCodes[n-1]
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"errcode": code,
+			"errmsg":  "mock",
+			"msgid":   1000 + n,
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestClient(t *testing.T, f *fakeServer) *Client {
+	t.Helper()
+	if f.expiresIn == 0 {
+		f.expiresIn = 7200
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c := NewClient("appid", "secret")
+	c.BaseURL = srv.URL
+	return c
+}
+
+func TestAccessTokenCached(t *testing.T) {
+	f := &fakeServer{}
+	c := newTestClient(t, f)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.AccessToken(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	token, err := c.AccessToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-1" || f.tokens != 1 {
+		t.Errorf("token = %q after %d fetches, want token-1 after 1", token, f.tokens)
+	}
+}
+
+func TestAccessTokenRefreshBeforeExpiry(t *testing.T) {
+	// 有效期短于提前刷新时间，每次都需要重新获取
+	f := &fakeServer{expiresIn: int64(refreshBefore / time.Second)}
+	c := newTestClient(t, f)
+
+	for i := 1; i <= 2; i++ {
+		token, err := c.AccessToken(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "token-" + strconv.Itoa(i); token != want {
+			t.Errorf("token = %q, want %q", token, want)
+		}
+	}
+}
+
+func TestAccessTokenError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errcode":40013,"errmsg":"invalid appid"}`))
+	}))
+	defer srv.Close()
+	c := NewClient("appid", "secret")
+	c.BaseURL = srv.URL
+
+	if _, err := c.AccessToken(context.Background()); !errors.Is(err, ErrCredential) {
+		t.Errorf("err = %v, want ErrCredential", err)
+	}
+}
+
+func TestInvalidateToken(t *testing.T) {
+	f := &fakeServer{}
+	c := newTestClient(t, f)
+	token, _ := c.AccessToken(context.Background())
+
+	// 其他 token 失效不影响当前缓存
+	c.InvalidateToken("stale")
+	if got, _ := c.AccessToken(context.Background()); got != token {
+		t.Errorf("token = %q, want cached %q", got, token)
+	}
+
+	c.InvalidateToken(token)
+	if got, _ := c.AccessToken(context.Background()); got != "token-2" {
+		t.Errorf("token = %q, want refreshed token-2", got)
+	}
+}
+
+func TestSendSubscribeMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		codes     []int
+		wantErr   error
+		wantID    int64
+		sends     int32
+		tokens    int32
+		lastToken string
+	}{
+		{name: "success", wantID: 1001, sends: 1, tokens: 1, lastToken: "token-1"},
+		{name: "retry on 40001", codes: []int{40001}, wantID: 1002, sends: 2, tokens: 2, lastToken: "token-2"},
+		{name: "retry on 42001", codes: []int{42001}, wantID: 1002, sends: 2, tokens: 2, lastToken: "token-2"},
+		{name: "retry only once", codes: []int{40001, 42001}, wantErr: ErrTokenInvalid, sends: 2, tokens: 2, lastToken: "token-2"},
+		{name: "user refused", codes: []int{43101}, wantErr: ErrUserRefused, sends: 1, tokens: 1, lastToken: "token-1"},
+		{name: "invalid template", codes: []int{40037}, wantErr: ErrInvalidTemplate, sends: 1, tokens: 1, lastToken: "token-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeServer{sendCodes: tt.codes}
+			c := newTestClient(t, f)
+			msgID, err := c.SendSubscribeMessage(context.Background(), &SubscribeMessage{
+				ToUser:     "openid",
+				TemplateID: "tpl",
+				Data:       map[string]DataItem{"thing1": {Value: "v"}},
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || msgID != tt.wantID {
+				t.Fatalf("msgID, err = %d, %v, want %d", msgID, err, tt.wantID)
+			}
+			if f.sends != tt.sends || f.tokens != tt.tokens || f.lastToken != tt.lastToken {
+				t.Errorf("sends=%d tokens=%d last=%q, want %d %d %q",
+					f.sends, f.tokens, f.lastToken, tt.sends, tt.tokens, tt.lastToken)
+			}
+		})
+	}
+}
+
+func TestSendSubscribeMessageDefaults(t *testing.T) {
+	var got SubscribeMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cgi-bin/token" {
+			_, _ = w.Write([]byte(`{"access_token":"t","expires_in":7200}`))
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"errcode":0,"msgid":1}`))
+	}))
+	defer srv.Close()
+	c := NewClient("appid", "secret")
+	c.BaseURL = srv.URL
+
+	if _, err := c.SendSubscribeMessage(context.Background(), &SubscribeMessage{ToUser: "u"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.MiniProgramState != "formal" || got.Lang != "zh_CN" {
+		t.Errorf("state, lang = %q, %q, want formal, zh_CN", got.MiniProgramState, got.Lang)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	tests := []struct {
+		code      int
+		want      error
+		temporary bool
+	}{
+		{-1, ErrBusy, true},
+		{40001, ErrTokenInvalid, true},
+		{40014, ErrTokenInvalid, true},
+		{42001, ErrTokenInvalid, true},
+		{40013, ErrCredential, false},
+		{40125, ErrCredential, false},
+		{43101, ErrUserRefused, false},
+		{40003, ErrInvalidOpenID, false},
+		{40037, ErrInvalidTemplate, false},
+		{47003, ErrInvalidParam, false},
+		{41030, ErrInvalidPage, false},
+		{45009, ErrRateLimited, true},
+		{45011, ErrRateLimited, true},
+		{99999, nil, false},
+	}
+	for _, tt := range tests {
+		err := &Error{Code: tt.code}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%d: errors.Is(%v) = false", tt.code, tt.want)
+		}
+		if got := err.Unwrap(); tt.want == nil && got != nil {
+			t.Errorf("%d: Unwrap = %v, want nil", tt.code, got)
+		}
+		if got := err.Temporary(); got != tt.temporary {
+			t.Errorf("%d: Temporary = %v, want %v", tt.code, got, tt.temporary)
+		}
+	}
+	if !IsTemporary(errors.New("network")) {
+		t.Error("network errors should be temporary")
+	}
+	if IsTemporary(nil) {
+		t.Error("nil should not be temporary")
+	}
+}
@@ -0,0 +1,78 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTokenInvalid access_token 无效或已过期，需要重新获取
+	ErrTokenInvalid = errors.New("wechat access token invalid")
+	// ErrCredential appid 或 secret 错误
+	ErrCredential = errors.New("wechat credential invalid")
+	// ErrUserRefused 用户拒绝接收消息或订阅次数已用完
+	ErrUserRefused = errors.New("wechat user refused subscribe message")
+	// ErrInvalidOpenID openid 无效
+	ErrInvalidOpenID = errors.New("wechat invalid openid")
+	// ErrInvalidTemplate 模版编号无效
+	ErrInvalidTemplate = errors.New("wechat invalid template id")
+	// ErrInvalidParam 模版参数不正确
+	ErrInvalidParam = errors.New("wechat invalid template data")
+	// ErrInvalidPage 跳转页面无效
+	ErrInvalidPage = errors.New("wechat invalid page")
+	// ErrRateLimited 接口调用超过限额
+	ErrRateLimited = errors.New("wechat api rate limited")
+	// ErrBusy 系统繁忙，稍后重试
+	ErrBusy = errors.New("wechat system busy")
+)
+
+// codes 微信错误码与错误类型的对应关系
+var codes = map[int]error{
+	-1:    ErrBusy,
+	40001: ErrTokenInvalid,
+	40014: ErrTokenInvalid,
+	42001: ErrTokenInvalid,
+	40013: ErrCredential,
+	40125: ErrCredential,
+	43101: ErrUserRefused,
+	40003: ErrInvalidOpenID,
+	40037: ErrInvalidTemplate,
+	47003: ErrInvalidParam,
+	41030: ErrInvalidPage,
+	45009: ErrRateLimited,
+	45011: ErrRateLimited,
+}
+
+// Error 微信接口返回的错误
+type Error struct {
+	// Code 错误码
+	Code int `json:"errcode"`
+	// Msg 错误信息
+	Msg string `json:"errmsg"`
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	return fmt.Sprintf("wechat error %d: %s", e.Code, e.Msg)
+}
+
+// Unwrap 返回错误码对应的错误类型，便于使用 errors.Is 判断
+func (e *Error) Unwrap() error {
+	return codes[e.Code]
+}
+
+// Temporary 是否为临时错误（可稍后重试）
+func (e *Error) Temporary() bool {
+	return IsTemporary(e)
+}
+
+// IsTemporary 判断错误是否可重试
+// 系统繁忙、限流、token 失效以及网络错误可以重试，其余为永久错误
+func IsTemporary(err error) bool {
+	var we *Error
+	if !errors.As(err, &we) {
+		// 非微信业务错误（网络、超时等）
+		return err != nil
+	}
+	return errors.Is(err, ErrBusy) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTokenInvalid)
+}